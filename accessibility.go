@@ -0,0 +1,172 @@
+package main
+
+/**
+ * Not leaning on color alone: words next to the colors for terminals (and people) without them, and a palette that
+ * doesn't put red against green.
+ */
+
+import (
+	"sync"
+)
+
+////////////////////////////////////////////
+// Utility: Severity
+////////////////////////////////////////////
+
+type Severity int
+
+const (
+	SeverityOK Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+var SeverityNames = []string{"ok", "warning", "critical"}
+
+// What goes in front of the text when markers are on
+var SeverityMarkers = []string{"OK", "WARN", "!!"}
+
+func (s Severity) String() string {
+	return SeverityNames[s]
+}
+
+// How good a value is, from 0 (worst) to PercentBands-1 (best)
+const PercentBands = 6
+
+// Where value is in the min/max range.  With invert, "good" is close to min and "bad" is closer to max.
+func percentToBand(value int, minValue int, maxValue int, invert bool) int {
+	span := float64(maxValue - minValue)
+	fvalue := float64(value)
+
+	if invert {
+		switch {
+		case fvalue > 0.90*span:
+			return 0
+		case fvalue > 0.75*span:
+			return 1
+		case fvalue > 0.50*span:
+			return 2
+		case fvalue > 0.25*span:
+			return 3
+		case fvalue > 0.05*span:
+			return 4
+		}
+	} else {
+		switch {
+		case fvalue < 0.10*span:
+			return 0
+		case fvalue < 0.25*span:
+			return 1
+		case fvalue < 0.50*span:
+			return 2
+		case fvalue < 0.75*span:
+			return 3
+		case fvalue < 0.95*span:
+			return 4
+		}
+	}
+
+	return PercentBands - 1
+}
+
+func bandToSeverity(band int) Severity {
+	switch {
+	case band <= 1:
+		return SeverityCritical
+	case band == 2:
+		return SeverityWarning
+	}
+
+	return SeverityOK
+}
+
+func percentToSeverity(value int, minValue int, maxValue int, invert bool) Severity {
+	return bandToSeverity(percentToBand(value, minValue, maxValue, invert))
+}
+
+////////////////////////////////////////////
+// Utility: Themes
+////////////////////////////////////////////
+
+const (
+	ThemeDefault      = "default"
+	ThemeHighContrast = "high-contrast"
+)
+
+// Markup colors for each band, worst to best
+var ThemePalettes = map[string][PercentBands]string{
+	ThemeDefault: {"fg-red,fg-bold", "fg-red", "fg-yellow,fg-bold", "fg-green", "fg-green,fg-bold", "fg-blue,fg-bold"},
+	// Bright yellow against blue, which (almost) everybody can tell apart, with the worst underlined as well
+	ThemeHighContrast: {"fg-yellow,fg-bold,fg-underline", "fg-yellow,fg-bold", "fg-white,fg-bold", "fg-cyan",
+		"fg-cyan,fg-bold", "fg-blue,fg-bold"},
+}
+
+// The color for a severity that doesn't come from a percent (like kerberos)
+func severityToColorString(severity Severity) string {
+	switch severity {
+	case SeverityCritical:
+		return getPalette()[0]
+	case SeverityWarning:
+		return getPalette()[2]
+	}
+
+	return getPalette()[3]
+}
+
+////////////////////////////////////////////
+// Utility: Severity Markers
+////////////////////////////////////////////
+
+const (
+	SymbolsAuto = "auto"
+	SymbolsOn   = "on"
+	SymbolsOff  = "off"
+)
+
+// Auto puts them in when color can't be trusted to say it: no colors at all, or someone who asked for high contrast
+func shouldShowSeverityMarkers(setting string, theme string, mode ColorMode) bool {
+	switch setting {
+	case SymbolsOn:
+		return true
+	case SymbolsOff:
+		return false
+	}
+
+	return mode == ColorModeNone || theme == ThemeHighContrast
+}
+
+// Like "!! 5% free", when markers are on
+func withSeverityMarker(text string, severity Severity) string {
+	if !getShowSeverityMarkers() {
+		return text
+	}
+
+	return SeverityMarkers[severity] + " " + text
+}
+
+////////////////////////////////////////////
+// Utility: Accessibility Settings
+////////////////////////////////////////////
+
+// Worked out the first time they're needed (after the flags are parsed), so a bad setting only gets logged once
+var accessibilitySettingsOnce sync.Once
+var activePalette [PercentBands]string
+var showSeverityMarkers bool
+
+func loadAccessibilitySettings() {
+	accessibilitySettingsOnce.Do(func() {
+		theme := GetTheme()
+		activePalette = ThemePalettes[theme]
+		showSeverityMarkers = shouldShowSeverityMarkers(GetSymbolsSetting(), theme, GetColorMode())
+	})
+}
+
+func getPalette() [PercentBands]string {
+	loadAccessibilitySettings()
+	return activePalette
+}
+
+func getShowSeverityMarkers() bool {
+	loadAccessibilitySettings()
+	return showSeverityMarkers
+}
@@ -0,0 +1,370 @@
+package main
+
+/**
+ * Agent mode: one long-running collector serving its state over a Unix socket, so any number of lightweight
+ * clients can attach without each one walking for repos and running git status themselves.
+ *
+ * The protocol is newline-delimited JSON.  The agent sends an AgentMessage as soon as a client connects, then another
+ * after every update.  Clients don't send anything.
+ */
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	ui "github.com/gizak/termui"
+)
+
+////////////////////////////////////////////
+// Utility: Agent Protocol
+////////////////////////////////////////////
+
+type AgentMessage struct {
+	State   DashboardState `json:"state"`
+	Metrics []Metric       `json:"metrics"`
+}
+
+// Widgets size their history by their width, so pretend to be a big terminal
+const AgentLayoutWidth = 200
+
+const AgentUpdateInterval = 5 * time.Second
+
+////////////////////////////////////////////
+// Utility: Agent Server
+////////////////////////////////////////////
+
+type AgentServer struct {
+	listener net.Listener
+	lock     sync.Mutex
+	clients  map[chan AgentMessage]bool
+}
+
+func NewAgentServer(socketPath string) (*AgentServer, error) {
+	// Clean up after an agent that didn't exit cleanly, unless it's still alive
+	if conn, err := net.Dial("unix", socketPath); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("an agent is already listening on %v", socketPath)
+	}
+	os.Remove(socketPath)
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only for us
+	os.Chmod(socketPath, 0600)
+
+	s := &AgentServer{
+		listener: listener,
+		clients:  make(map[chan AgentMessage]bool),
+	}
+
+	latestDashboardState.addListener(s.broadcast)
+
+	return s, nil
+}
+
+// Clients that connect before this wait in the listen queue
+func (s *AgentServer) start() {
+	go s.accept()
+}
+
+func (s *AgentServer) currentMessage(state DashboardState) AgentMessage {
+	_, metrics := latestMetrics.get()
+
+	return AgentMessage{State: state, Metrics: metrics}
+}
+
+func (s *AgentServer) broadcast(state DashboardState) {
+	message := s.currentMessage(state)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for ch := range s.clients {
+		// Skip clients that are behind, they'll get the next one
+		select {
+		case ch <- message:
+		default:
+		}
+	}
+}
+
+func (s *AgentServer) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			log.Printf("Agent stopped accepting clients: %v", err)
+			return
+		}
+
+		go s.serve(conn)
+	}
+}
+
+func (s *AgentServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	ch := make(chan AgentMessage, 1)
+	ch <- s.currentMessage(latestDashboardState.get())
+
+	s.lock.Lock()
+	s.clients[ch] = true
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		delete(s.clients, ch)
+		s.lock.Unlock()
+	}()
+
+	encoder := json.NewEncoder(conn)
+
+	for message := range ch {
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+
+		if err := encoder.Encode(message); err != nil {
+			log.Printf("Dropping agent client: %v", err)
+			return
+		}
+	}
+}
+
+func (s *AgentServer) Close() {
+	s.listener.Close()
+}
+
+////////////////////////////////////////////
+// Utility: Agent Client
+////////////////////////////////////////////
+
+type AgentClient struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+}
+
+func DialAgent(socketPath string) (*AgentClient, error) {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(conn)
+	// States with lots of repos get big
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	return &AgentClient{conn: conn, scanner: scanner}, nil
+}
+
+// Blocks until the next message shows up
+func (c *AgentClient) Next() (AgentMessage, error) {
+	var message AgentMessage
+
+	if !c.scanner.Scan() {
+		err := c.scanner.Err()
+		if err == nil {
+			err = fmt.Errorf("agent closed the connection")
+		}
+
+		return message, err
+	}
+
+	err := json.Unmarshal(c.scanner.Bytes(), &message)
+
+	return message, err
+}
+
+func (c *AgentClient) Close() {
+	c.conn.Close()
+}
+
+////////////////////////////////////////////
+// Utility: Headless Collector
+////////////////////////////////////////////
+
+// Runs the dashboard widgets without a terminal, for anything that only wants their state
+type HeadlessCollector struct {
+	dashboard *Dashboard
+	grid      *ui.Grid
+}
+
+func NewHeadlessCollector() *HeadlessCollector {
+	dashboard := NewHeadlessDashboard()
+
+	// Lay the widgets out so they have sizes
+	grid := ui.NewGrid(dashboard.buildRows()...)
+	grid.Width = AgentLayoutWidth
+	grid.Align()
+
+	for _, w := range dashboard.getWidgets() {
+		w.resize()
+	}
+
+	c := &HeadlessCollector{
+		dashboard: dashboard,
+		grid:      grid,
+	}
+
+	c.update()
+
+	return c
+}
+
+// Records to latestMetrics and latestDashboardState like the terminal dashboard does
+func (c *HeadlessCollector) update() {
+	for _, w := range c.dashboard.getWidgets() {
+		w.update()
+	}
+
+	c.grid.Align()
+	c.dashboard.afterUpdate()
+}
+
+// Framed like the terminal, as tall as it needs to be
+func (c *HeadlessCollector) screenshot(format string) (string, error) {
+	c.grid.X = 1
+	c.grid.Y = 1
+	c.grid.Align()
+
+	height := 0
+	for _, row := range c.grid.Rows {
+		height += row.GetHeight()
+	}
+
+	frame := ui.NewParagraph("")
+	frame.BorderFg = ui.ColorCyan | ui.AttrBold
+	frame.BorderLabel = c.dashboard.userHostHeader
+	frame.Width = c.grid.Width + 2
+	frame.Height = height + 2
+
+	return renderScreenshot(format, frame.Width, frame.Height, frame, c.grid)
+}
+
+func (c *HeadlessCollector) currentMessage() AgentMessage {
+	_, metrics := latestMetrics.get()
+
+	return AgentMessage{State: latestDashboardState.get(), Metrics: metrics}
+}
+
+////////////////////////////////////////////
+// Commands: agent and attach
+////////////////////////////////////////////
+
+func runAgent() {
+	// Before collecting anything, which is slow (git, weather, ...) and pointless if another agent has the socket
+	server, err := NewAgentServer(GetAgentSocketPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting agent: %v\n", err)
+		os.Exit(1)
+	}
+	defer server.Close()
+
+	startHistory()
+
+	// Have something for the first client
+	collector := NewHeadlessCollector()
+
+	server.start()
+	startExporters()
+
+	ticker := time.NewTicker(AgentUpdateInterval)
+
+	for range ticker.C {
+		collector.update()
+	}
+}
+
+// Collect once and print it, for scripts (and for aggregating remote hosts over SSH)
+func runOnce() {
+	collector := NewHeadlessCollector()
+
+	switch format := GetOnceFormat(); format {
+	case "json":
+		if err := json.NewEncoder(os.Stdout).Encode(collector.currentMessage()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing JSON: %v\n", err)
+			os.Exit(1)
+		}
+	case "ansi", "html", "svg":
+		screenshot, err := collector.screenshot(format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error rendering screenshot: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Print(screenshot)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown output format '%v'\n", format)
+		os.Exit(2)
+	}
+}
+
+// Keeps the remote dashboard fed, reconnecting whenever the agent goes away
+func followAgent(socketPath string, client *AgentClient, dashboard *RemoteDashboard) {
+	for {
+		message, err := client.Next()
+
+		if err == nil {
+			dashboard.setState(message.State)
+			continue
+		}
+
+		log.Printf("Lost the agent: %v", err)
+		client.Close()
+		dashboard.setStatus("(agent disconnected)")
+
+		for {
+			time.Sleep(2 * time.Second)
+
+			client, err = DialAgent(socketPath)
+			if err == nil {
+				break
+			}
+		}
+	}
+}
+
+func runAttach() {
+	socketPath := GetAgentSocketPath()
+
+	client, err := DialAgent(socketPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error connecting to agent at %v (is `sysdash agent` running?): %v\n", socketPath, err)
+		os.Exit(1)
+	}
+
+	first, err := client.Next()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading from agent: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Set up the console UI
+	uiErr := ui.Init()
+	if uiErr != nil {
+		panic(uiErr)
+	}
+	defer ui.Close()
+
+	initTerminalColors(GetColorMode())
+
+	dashboard := NewRemoteDashboard(first.State)
+
+	go followAgent(socketPath, client, dashboard)
+
+	// Give space around the ui.Body for the header box to wrap all around
+	ui.Body.Width = ui.TermWidth() - 2
+	ui.Body.X = 1
+	ui.Body.Y = 1
+
+	ui.Body.AddRows(dashboard.buildRows()...)
+	ui.Body.Align()
+
+	loop(dashboard)
+}
@@ -0,0 +1,164 @@
+package main
+
+/**
+ * Draw termui buffers as ANSI escape codes, for terminals that aren't the one termbox is running on.
+ */
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	ui "github.com/gizak/termui"
+)
+
+////////////////////////////////////////////
+// Utility: ANSI Rendering
+////////////////////////////////////////////
+
+// The color part of an attribute, the rest are flags like bold
+const AttributeColorMask = 0x1FF
+
+const ANSIReset = "\x1b[0m"
+
+// The SGR sequence that switches to these colors (or the closest the mode has), starting from a reset so nothing
+// carries over
+func attributesToSGR(fg ui.Attribute, bg ui.Attribute, mode ColorMode) string {
+	if mode == ColorModeNone && bg&AttributeColorMask != ui.ColorDefault {
+		// Without colors a background is only visible reversed, same as on the terminal
+		fg |= ui.AttrReverse
+	}
+
+	fg = adaptAttribute(fg, mode)
+	bg = adaptAttribute(bg, mode)
+
+	codes := []string{"0"}
+
+	if fg&ui.AttrBold != 0 {
+		codes = append(codes, "1")
+	}
+	if fg&ui.AttrUnderline != 0 {
+		codes = append(codes, "4")
+	}
+	// termbox takes it from either
+	if (fg|bg)&ui.AttrReverse != 0 {
+		codes = append(codes, "7")
+	}
+
+	addColor := func(attr ui.Attribute, base int) {
+		color := int(attr & AttributeColorMask)
+
+		if color == 0 {
+			// Default, the reset took care of it
+			return
+		} else if color <= 8 {
+			codes = append(codes, strconv.Itoa(base+color-1))
+		} else if mode == ColorModeTrueColor {
+			// Exactly the palette's color, whatever the terminal's palette is
+			r, g, b := xterm256ToRGB(color - 1)
+			codes = append(codes, fmt.Sprintf("%d;2;%d;%d;%d", base+8, r, g, b))
+		} else {
+			// 256 color mode is off by one, like termbox
+			codes = append(codes, fmt.Sprintf("%d;5;%d", base+8, color-1))
+		}
+	}
+
+	addColor(fg, 30)
+	addColor(bg, 40)
+
+	return "\x1b[" + strings.Join(codes, ";") + "m"
+}
+
+// One string per line, each ending with a reset
+func renderBufferLines(buf ui.Buffer, width int, height int, mode ColorMode) []string {
+	lines := make([]string, height)
+
+	for y := 0; y < height; y++ {
+		var line strings.Builder
+		lastSGR := ""
+
+		for x := 0; x < width; x++ {
+			cell := buf.At(x, y)
+
+			sgr := attributesToSGR(cell.Fg, cell.Bg, mode)
+			if sgr != lastSGR {
+				line.WriteString(sgr)
+				lastSGR = sgr
+			}
+
+			if cell.Ch == 0 {
+				line.WriteRune(' ')
+			} else {
+				line.WriteRune(cell.Ch)
+
+				// termbox leaves a placeholder in the cells a wide rune covers, the terminal already moved past them
+				if w := runeDisplayWidth(cell.Ch); w > 1 {
+					x += w - 1
+				}
+			}
+		}
+
+		line.WriteString(ANSIReset)
+		lines[y] = line.String()
+	}
+
+	return lines
+}
+
+// Draws the bufferers over a blank screen, in order, the same way ui.Render does
+func renderToBuffer(width int, height int, bs ...ui.Bufferer) ui.Buffer {
+	buf := ui.NewFilledBuffer(0, 0, width, height, ' ', ui.ColorDefault, ui.ColorDefault)
+
+	for _, b := range bs {
+		buf.Merge(b.Buffer())
+	}
+
+	return buf
+}
+
+////////////////////////////////////////////
+// Utility: ANSI Screen
+////////////////////////////////////////////
+
+// Remembers what's on a remote terminal so redraws only send the lines that changed
+type ANSIScreen struct {
+	width  int
+	height int
+	mode   ColorMode
+	lines  []string
+}
+
+func NewANSIScreen(width int, height int, mode ColorMode) *ANSIScreen {
+	return &ANSIScreen{width: width, height: height, mode: mode}
+}
+
+func (s *ANSIScreen) resize(width int, height int) {
+	s.width = width
+	s.height = height
+
+	// Everything moved, start over
+	s.lines = nil
+}
+
+// What to write to the terminal to get it showing these bufferers
+func (s *ANSIScreen) draw(bs ...ui.Bufferer) string {
+	lines := renderBufferLines(renderToBuffer(s.width, s.height, bs...), s.width, s.height, s.mode)
+
+	var out strings.Builder
+
+	if s.lines == nil {
+		out.WriteString("\x1b[2J")
+	}
+
+	for y, line := range lines {
+		if s.lines != nil && y < len(s.lines) && s.lines[y] == line {
+			continue
+		}
+
+		fmt.Fprintf(&out, "\x1b[%d;1H%s", y+1, line)
+	}
+
+	s.lines = lines
+
+	return out.String()
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	ui "github.com/gizak/termui"
+)
+
+func TestRenderBufferLinesWideRunes(t *testing.T) {
+	buf := ui.NewFilledBuffer(0, 0, 6, 1, ' ', ui.ColorDefault, ui.ColorDefault)
+
+	// termbox style: the wide rune in one cell and a placeholder in the one after
+	for x, ch := range []rune{'│', '世', 0, 'a', '│'} {
+		buf.Set(x, 0, ui.Cell{Ch: ch})
+	}
+
+	lines := renderBufferLines(buf, 6, 1, ColorModeNone)
+
+	if want := "\x1b[0m│世a│ " + ANSIReset; lines[0] != want {
+		t.Errorf("got %q, want %q", lines[0], want)
+	}
+
+	if width := displayWidth(stripANSI(lines[0])); width != 6 {
+		t.Errorf("line is %d columns wide, want 6", width)
+	}
+}
@@ -0,0 +1,517 @@
+package main
+
+/**
+ * Read text with ANSI escape codes in it (like what wttr.in sends) into termui colors.
+ */
+
+import (
+	"strconv"
+	"strings"
+
+	ui "github.com/gizak/termui"
+	runewidth "github.com/mattn/go-runewidth"
+)
+
+////////////////////////////////////////////
+// Utility: SGR State
+////////////////////////////////////////////
+
+// What all the SGR codes so far add up to.  Zero is the default colors with nothing set.
+type SGRState struct {
+	Fg ui.Attribute
+	Bg ui.Attribute
+}
+
+// Colors 256 color style, off by one like termbox
+func paletteAttribute(index int) ui.Attribute {
+	if index < 0 || index > 255 {
+		return ui.ColorDefault
+	}
+
+	return ui.Attribute(index + 1)
+}
+
+func setColor(attr ui.Attribute, color ui.Attribute) ui.Attribute {
+	return (attr &^ AttributeColorMask) | color
+}
+
+// For 38 and 48: "5;n" or "2;r;g;b" after them.  Returns the color and how many of codes it used.
+func parseExtendedColor(codes []string) (ui.Attribute, int) {
+	number := func(i int) int {
+		if i >= len(codes) {
+			return 0
+		}
+
+		n, _ := strconv.Atoi(codes[i])
+		return n
+	}
+
+	switch {
+	case number(0) == 5 && len(codes) >= 2:
+		return paletteAttribute(number(1)), 2
+	case number(0) == 2 && len(codes) >= 4:
+		return paletteAttribute(rgbToXterm256(number(1), number(2), number(3))), 4
+	case number(0) == 5 || number(0) == 2:
+		// Cut short, so not really a color
+		return ui.ColorDefault, len(codes)
+	}
+
+	// Nothing we know, skip just the kind
+	return ui.ColorDefault, 1
+}
+
+// The parameters from one "ESC [ ... m", like "1;38;5;226"
+func (s *SGRState) apply(params string) {
+	codes := strings.Split(params, ";")
+
+	for i := 0; i < len(codes); i++ {
+		// The colon form keeps a whole color in one parameter, like "38:2::255:128:0"
+		if subcodes := strings.Split(codes[i], ":"); len(subcodes) > 1 {
+			if subcodes[1] == "2" && len(subcodes) == 6 {
+				// With the color space ID, which nobody sets
+				subcodes = append(subcodes[:2], subcodes[3:]...)
+			}
+
+			color, _ := parseExtendedColor(subcodes[1:])
+
+			switch subcodes[0] {
+			case "38":
+				s.Fg = setColor(s.Fg, color)
+			case "48":
+				s.Bg = setColor(s.Bg, color)
+			}
+
+			continue
+		}
+
+		// Empty is the same as 0
+		code, _ := strconv.Atoi(codes[i])
+
+		switch {
+		case code == 0:
+			*s = SGRState{}
+		case code == 1:
+			s.Fg |= ui.AttrBold
+		case code == 22:
+			s.Fg &^= ui.AttrBold
+		case code == 4:
+			s.Fg |= ui.AttrUnderline
+		case code == 24:
+			s.Fg &^= ui.AttrUnderline
+		case code == 7:
+			s.Fg |= ui.AttrReverse
+		case code == 27:
+			s.Fg &^= ui.AttrReverse
+		case code >= 30 && code <= 37:
+			s.Fg = setColor(s.Fg, paletteAttribute(code-30))
+		case code == 39:
+			s.Fg = setColor(s.Fg, ui.ColorDefault)
+		case code >= 40 && code <= 47:
+			s.Bg = setColor(s.Bg, paletteAttribute(code-40))
+		case code == 49:
+			s.Bg = setColor(s.Bg, ui.ColorDefault)
+		case code >= 90 && code <= 97:
+			// The bright ones are the second 8 of the palette
+			s.Fg = setColor(s.Fg, paletteAttribute(code-90+8))
+		case code >= 100 && code <= 107:
+			s.Bg = setColor(s.Bg, paletteAttribute(code-100+8))
+		case code == 38 || code == 48:
+			color, used := parseExtendedColor(codes[i+1:])
+
+			if code == 38 {
+				s.Fg = setColor(s.Fg, color)
+			} else {
+				s.Bg = setColor(s.Bg, color)
+			}
+
+			i += used
+		}
+
+		// Everything else (italics, blinking, ...) termui can't show anyway
+	}
+}
+
+////////////////////////////////////////////
+// Utility: ANSI Parsing
+////////////////////////////////////////////
+
+// A stretch of text that all looks the same
+type ANSIRun struct {
+	Text  string
+	State SGRState
+}
+
+// Where the parser is in an escape sequence
+const (
+	ansiParseText = iota
+	// Just saw ESC
+	ansiParseEscape
+	// ESC [, until the final byte
+	ansiParseCSI
+	// ESC ], ESC P and friends, until BEL or ESC \
+	ansiParseString
+	// ESC inside a string, which should be the \ that ends it
+	ansiParseStringEscape
+)
+
+// Only SGR changes what comes out, every other escape sequence (cursor movement, clearing, titles) gets dropped, along
+// with control characters other than newlines and tabs
+func parseANSI(ansi string) []ANSIRun {
+	runs := make([]ANSIRun, 0)
+	state := SGRState{}
+	parseState := ansiParseText
+
+	var text strings.Builder
+	var params strings.Builder
+
+	// Whatever's built up so far gets the state it was written in
+	flush := func() {
+		if text.Len() <= 0 {
+			return
+		}
+
+		if len(runs) > 0 && runs[len(runs)-1].State == state {
+			runs[len(runs)-1].Text += text.String()
+		} else {
+			runs = append(runs, ANSIRun{Text: text.String(), State: state})
+		}
+
+		text.Reset()
+	}
+
+	for _, r := range ansi {
+		switch parseState {
+		case ansiParseText:
+			switch {
+			case r == '\x1b':
+				parseState = ansiParseEscape
+			case r == '\n' || r == '\t':
+				text.WriteRune(r)
+			case r < ' ' || r == '\x7f':
+				// Carriage returns, bells, backspaces
+			default:
+				text.WriteRune(r)
+			}
+
+		case ansiParseEscape:
+			switch r {
+			case '[':
+				params.Reset()
+				parseState = ansiParseCSI
+			case ']', 'P', 'X', '^', '_':
+				parseState = ansiParseString
+			case '\x1b':
+				// The first one went nowhere, this one starts the real sequence
+			default:
+				// Two character sequences, like ESC 7 (save the cursor)
+				parseState = ansiParseText
+			}
+
+		case ansiParseCSI:
+			switch {
+			case r >= 0x40 && r <= 0x7e:
+				if r == 'm' {
+					flush()
+					state.apply(params.String())
+				}
+
+				parseState = ansiParseText
+			case r == '\x1b':
+				// Cut off by another sequence
+				parseState = ansiParseEscape
+			case r < 0x20 || r > 0x7e:
+				// Not a real sequence, give up on it
+				parseState = ansiParseText
+			default:
+				params.WriteRune(r)
+			}
+
+		case ansiParseString:
+			switch r {
+			case '\a':
+				parseState = ansiParseText
+			case '\x1b':
+				parseState = ansiParseStringEscape
+			}
+
+		case ansiParseStringEscape:
+			if r == '\\' {
+				parseState = ansiParseText
+			} else {
+				parseState = ansiParseString
+			}
+		}
+	}
+
+	flush()
+
+	return runs
+}
+
+// Just the text
+func stripANSI(ansi string) string {
+	var out strings.Builder
+
+	for _, run := range parseANSI(ansi) {
+		out.WriteString(run.Text)
+	}
+
+	return out.String()
+}
+
+////////////////////////////////////////////
+// Utility: ANSI to Markup
+////////////////////////////////////////////
+
+// termui markup has no way to escape brackets, so the ones that would get read as markup become lookalikes
+const (
+	MarkupOpenBracket  = "⁅"
+	MarkupCloseBracket = "⁆"
+)
+
+var markupEscaper = strings.NewReplacer("[", MarkupOpenBracket, "]", MarkupCloseBracket)
+
+// For text that goes inside [text](fg-color), where any bracket would end it early (or not at all)
+func escapeMarkupSegment(text string) string {
+	return markupEscaper.Replace(text)
+}
+
+// For text outside of markup.  Brackets that are just brackets, like "[kworker/0:1]", stay.
+func escapeMarkup(text string) string {
+	return escapeMarkupFollowedBy(text, 0)
+}
+
+// Only the [ that termui would read as the start of something becomes a lookalike: one that's never closed (it
+// swallows everything after it), one whose ] is followed by ( or another [, and any inside one with ]( in it.  next is
+// what comes after the text, 0 for nothing.
+func escapeMarkupFollowedBy(text string, next rune) string {
+	runes := []rune(text)
+
+	after := func(i int) rune {
+		if i+1 < len(runes) {
+			return runes[i+1]
+		}
+
+		return next
+	}
+
+	// Which ] closes which [, and the ones that never get closed
+	closes := make(map[int]int)
+	opens := make([]int, 0)
+
+	for i, r := range runes {
+		switch {
+		case r == '[':
+			opens = append(opens, i)
+		case r == ']' && len(opens) > 0:
+			closes[opens[len(opens)-1]] = i
+			opens = opens[:len(opens)-1]
+		}
+	}
+
+	escaped := make(map[int]bool)
+	for _, i := range opens {
+		escaped[i] = true
+	}
+
+	// Then each outermost pair, which termui reads as a whole
+	for i := 0; i < len(runes); i++ {
+		end, ok := closes[i]
+		if !ok {
+			continue
+		}
+
+		inside := string(runes[i:end])
+		if after(end) == '(' || after(end) == '[' || strings.Contains(inside, "](") {
+			for j := i; j < end; j++ {
+				if _, ok := closes[j]; ok {
+					escaped[j] = true
+				}
+			}
+		}
+
+		i = end
+	}
+
+	var out strings.Builder
+
+	for i, r := range runes {
+		if escaped[i] {
+			out.WriteString(MarkupOpenBracket)
+		} else {
+			out.WriteRune(r)
+		}
+	}
+
+	return out.String()
+}
+
+// Like "fg-red,fg-bold,bg-blue".  Markup only has names for the 8 colors, so the rest get the closest one.
+func sgrStateToColorString(state SGRState) string {
+	parts := make([]string, 0, 2)
+
+	if fg := attributeToColorString(adaptAttribute(state.Fg, ColorMode16), "fg"); len(fg) > 0 {
+		parts = append(parts, fg)
+	}
+
+	// Bold backgrounds don't mean anything
+	if bg := attributeToColorString(adaptAttribute(state.Bg, ColorMode16)&AttributeColorMask, "bg"); len(bg) > 0 {
+		parts = append(parts, bg)
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// The runs a line at a time, without the newlines
+func splitANSILines(runs []ANSIRun) [][]ANSIRun {
+	lines := [][]ANSIRun{{}}
+
+	for _, run := range runs {
+		for i, text := range strings.Split(run.Text, "\n") {
+			if i > 0 {
+				lines = append(lines, []ANSIRun{})
+			}
+
+			if len(text) > 0 {
+				lines[len(lines)-1] = append(lines[len(lines)-1], ANSIRun{Text: text, State: run.State})
+			}
+		}
+	}
+
+	return lines
+}
+
+// One line into termui's [text](fg-color) syntax
+func ansiLineToMarkup(line []ANSIRun) string {
+	var out strings.Builder
+
+	for i, run := range line {
+		colorString := sgrStateToColorString(run.State)
+
+		if len(colorString) > 0 {
+			out.WriteString("[" + escapeMarkupSegment(run.Text) + "](" + colorString + ")")
+		} else if i+1 < len(line) {
+			// Markup comes next
+			out.WriteString(escapeMarkupFollowedBy(run.Text, '['))
+		} else {
+			out.WriteString(escapeMarkup(run.Text))
+		}
+	}
+
+	return out.String()
+}
+
+// Into termui's [text](fg-color) syntax, a line at a time so the markup never spans a newline
+func ConvertANSIToColorStrings(ansi string) string {
+	lines := splitANSILines(parseANSI(ansi))
+	markup := make([]string, len(lines))
+
+	for i, line := range lines {
+		markup[i] = ansiLineToMarkup(line)
+	}
+
+	return strings.Join(markup, "\n")
+}
+
+func ansiLineWidth(line []ANSIRun) int {
+	width := 0
+
+	for _, run := range line {
+		width += runewidth.StringWidth(run.Text)
+	}
+
+	return width
+}
+
+// Like truncateToWidth, the ellipsis goes in the color of the text that got cut
+func truncateANSILine(line []ANSIRun, width int) []ANSIRun {
+	if ansiLineWidth(line) <= width {
+		return line
+	} else if width <= 0 {
+		return []ANSIRun{}
+	}
+
+	// Room for the ellipsis
+	budget := width - runewidth.StringWidth(Ellipsis)
+	used := 0
+
+	truncated := make([]ANSIRun, 0, len(line))
+
+	for _, run := range line {
+		var text strings.Builder
+
+		for _, r := range run.Text {
+			if used+runeDisplayWidth(r) > budget {
+				return append(truncated, ANSIRun{Text: text.String() + Ellipsis, State: run.State})
+			}
+
+			text.WriteRune(r)
+			used += runeDisplayWidth(r)
+		}
+
+		truncated = append(truncated, run)
+	}
+
+	return truncated
+}
+
+////////////////////////////////////////////
+// Widget: ANSI Paragraph
+////////////////////////////////////////////
+
+// A paragraph that draws ANSI text straight into the cells, so 256 colors and truecolor make it to the screen when the
+// terminal can show them (markup would have cut them down to 8).  Text keeps the markup version for everything that
+// only reads markup, like the remote dashboards.
+type ANSIParagraph struct {
+	ui.Paragraph
+	// Cut to fit, a line won't wrap
+	Lines [][]ANSIRun
+}
+
+func NewANSIParagraph() *ANSIParagraph {
+	return &ANSIParagraph{
+		Paragraph: *ui.NewParagraph(""),
+		Lines:     [][]ANSIRun{},
+	}
+}
+
+// Sets Lines and Text, with each line cut to width
+func (p *ANSIParagraph) setLines(lines [][]ANSIRun, width int) {
+	p.Lines = make([][]ANSIRun, len(lines))
+	markup := make([]string, len(lines))
+
+	for i, line := range lines {
+		p.Lines[i] = truncateANSILine(line, width)
+		markup[i] = ansiLineToMarkup(p.Lines[i])
+	}
+
+	p.Text = strings.Join(markup, "\n")
+}
+
+func (p *ANSIParagraph) Buffer() ui.Buffer {
+	buf := p.Block.Buffer()
+	area := p.InnerBounds()
+
+	for y, line := range p.Lines {
+		if y >= area.Dy() {
+			break
+		}
+
+		x := 0
+
+		for _, run := range line {
+			for _, r := range run.Text {
+				w := runeDisplayWidth(r)
+				if x+w > area.Dx() {
+					break
+				}
+
+				// A wide rune takes the cell after it too, like termui does it
+				buf.Set(area.Min.X+x, area.Min.Y+y, ui.Cell{Ch: r, Fg: run.State.Fg, Bg: run.State.Bg})
+				x += w
+			}
+		}
+	}
+
+	return buf
+}
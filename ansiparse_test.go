@@ -0,0 +1,245 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	ui "github.com/gizak/termui"
+)
+
+func TestSGRStateApply(t *testing.T) {
+	red256 := paletteAttribute(196)
+
+	tests := []struct {
+		name   string
+		start  SGRState
+		params string
+		want   SGRState
+	}{
+		{"reset", SGRState{Fg: ui.ColorRed | ui.AttrBold, Bg: ui.ColorBlue}, "0", SGRState{}},
+		{"empty is reset", SGRState{Fg: ui.ColorRed}, "", SGRState{}},
+		{"bold and color", SGRState{}, "1;31", SGRState{Fg: ui.ColorRed | ui.AttrBold}},
+		{"bold off keeps color", SGRState{Fg: ui.ColorRed | ui.AttrBold}, "22", SGRState{Fg: ui.ColorRed}},
+		{"underline and reverse", SGRState{}, "4;7", SGRState{Fg: ui.AttrUnderline | ui.AttrReverse}},
+		{"default colors keep flags", SGRState{Fg: ui.ColorRed | ui.AttrBold, Bg: ui.ColorBlue}, "39;49",
+			SGRState{Fg: ui.AttrBold}},
+		{"background", SGRState{}, "44", SGRState{Bg: ui.ColorBlue}},
+		{"bright", SGRState{}, "91;102", SGRState{Fg: paletteAttribute(9), Bg: paletteAttribute(10)}},
+		{"256 colors", SGRState{}, "38;5;196;48;5;21", SGRState{Fg: red256, Bg: paletteAttribute(21)}},
+		{"truecolor", SGRState{}, "38;2;255;0;0", SGRState{Fg: red256}},
+		{"truecolor then more", SGRState{}, "38;2;255;0;0;1", SGRState{Fg: red256 | ui.AttrBold}},
+		{"colon 256 colors", SGRState{}, "38:5:196", SGRState{Fg: red256}},
+		{"colon truecolor with color space", SGRState{}, "38:2::255:0:0", SGRState{Fg: red256}},
+		{"colon truecolor without color space", SGRState{}, "48:2:255:0:0", SGRState{Bg: red256}},
+		{"colon then semicolon", SGRState{}, "38:5:196;4", SGRState{Fg: red256 | ui.AttrUnderline}},
+		{"out of range palette", SGRState{Fg: ui.ColorRed}, "38;5;300", SGRState{}},
+		{"unknown extended skips only its kind", SGRState{}, "38;9;1", SGRState{Fg: ui.AttrBold}},
+		{"truncated 256 colors", SGRState{Fg: ui.ColorRed}, "38;5", SGRState{}},
+		{"truncated truecolor", SGRState{}, "1;48;2;255;0", SGRState{Fg: ui.AttrBold}},
+		{"truncated colon form", SGRState{Bg: ui.ColorRed}, "48:2:255", SGRState{}},
+		{"ignored codes", SGRState{Fg: ui.ColorGreen}, "3;5;9", SGRState{Fg: ui.ColorGreen}},
+	}
+
+	for _, test := range tests {
+		state := test.start
+		state.apply(test.params)
+
+		if state != test.want {
+			t.Errorf("%v: got %+v, want %+v", test.name, state, test.want)
+		}
+	}
+}
+
+func TestParseANSI(t *testing.T) {
+	red := SGRState{Fg: ui.ColorRed}
+	green := SGRState{Fg: ui.ColorGreen}
+
+	tests := []struct {
+		name string
+		ansi string
+		want []ANSIRun
+	}{
+		{"plain", "hello", []ANSIRun{{"hello", SGRState{}}}},
+		{"empty", "", []ANSIRun{}},
+		{"color and reset", "\x1b[31mred\x1b[0m plain", []ANSIRun{{"red", red}, {" plain", SGRState{}}}},
+		{"same state merges", "\x1b[31mA\x1b[31mB", []ANSIRun{{"AB", red}}},
+		{"codes with nothing between", "\x1b[31m\x1b[32mgo", []ANSIRun{{"go", green}}},
+		{"cursor movement dropped", "\x1b[2J\x1b[1;1Htext", []ANSIRun{{"text", SGRState{}}}},
+		{"title ended by BEL", "\x1b]0;title\atext", []ANSIRun{{"text", SGRState{}}}},
+		{"hyperlink ended by ESC \\", "\x1b]8;;http://x\x1b\\link\x1b]8;;\x1b\\", []ANSIRun{{"link", SGRState{}}}},
+		{"ESC in a string that isn't the end", "\x1b]0;a\x1bb\x1b\\text", []ANSIRun{{"text", SGRState{}}}},
+		{"DCS", "\x1bPq#0\x1b\\text", []ANSIRun{{"text", SGRState{}}}},
+		{"two character escape", "\x1b7text\x1b8", []ANSIRun{{"text", SGRState{}}}},
+		{"controls", "a\rb\bc\x07\n\td\x7f", []ANSIRun{{"abc\n\td", SGRState{}}}},
+		{"unfinished CSI", "ok\x1b[31", []ANSIRun{{"ok", SGRState{}}}},
+		{"broken CSI", "\x1b[3\nok", []ANSIRun{{"ok", SGRState{}}}},
+		{"ESC in a CSI starts over", "\x1b[3\x1b[32mgo", []ANSIRun{{"go", green}}},
+		{"ESC after ESC starts over", "\x1b\x1b[31mred", []ANSIRun{{"red", red}}},
+		{"unicode", "\x1b[31m世界\x1b[m!", []ANSIRun{{"世界", red}, {"!", SGRState{}}}},
+	}
+
+	for _, test := range tests {
+		if got := parseANSI(test.ansi); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestConvertANSIToColorStrings(t *testing.T) {
+	tests := []struct {
+		ansi string
+		want string
+	}{
+		{"plain", "plain"},
+		{"\x1b[31mred\x1b[0m plain", "[red](fg-red) plain"},
+		{"\x1b[1;32mbold green", "[bold green](fg-green,fg-bold)"},
+		{"\x1b[44mon blue", "[on blue](bg-blue)"},
+		// Markup never spans a line, and brackets inside it would end it early
+		{"\x1b[31mone\ntwo [x](fg-blue)\x1b[0m", "[one](fg-red)\n[two ⁅x⁆(fg-blue)](fg-red)"},
+		// Outside it only the ones that would start markup change
+		{"see [x] and [y](fg-blue)", "see [x] and ⁅y](fg-blue)"},
+		{"\x1b[31mred\x1b[0m [kworker/0:1]", "[red](fg-red) [kworker/0:1]"},
+		{"a [b]\x1b[31mred", "a ⁅b][red](fg-red)"},
+		{"\x1b[31m\n\x1b[0m", "\n"},
+	}
+
+	for _, test := range tests {
+		if got := ConvertANSIToColorStrings(test.ansi); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.ansi, got, test.want)
+		}
+	}
+
+	if got := stripANSI("\x1b[1mwttr\x1b[0m.in"); got != "wttr.in" {
+		t.Errorf("stripANSI gave %q", got)
+	}
+}
+
+func TestEscapeMarkup(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain", "plain"},
+		{"[kworker/0:1]", "[kworker/0:1]"},
+		{"[[a]] b] c", "[[a]] b] c"},
+		{"[a] (b)", "[a] (b)"},
+		{"[x](fg-red)", "⁅x](fg-red)"},
+		// A [ that's never closed swallows what's after it
+		{"a [b", "a ⁅b"},
+		{"[a [b] c", "⁅a [b] c"},
+		// termui reads these as a whole
+		{"[a][b]", "⁅a][b]"},
+		{"[a [b](fg-red)]", "⁅a ⁅b](fg-red)]"},
+	}
+
+	for _, test := range tests {
+		got := escapeMarkup(test.text)
+
+		if got != test.want {
+			t.Errorf("%q: got %q, want %q", test.text, got, test.want)
+		}
+
+		if MarkupRegexp.MatchString(got) {
+			t.Errorf("%q: %q is still markup", test.text, got)
+		}
+	}
+
+	// What comes after counts too
+	if got := escapeMarkupFollowedBy("a [b]", '['); got != "a ⁅b]" {
+		t.Errorf("before markup got %q", got)
+	}
+
+	if got := escapeMarkupFollowedBy("a [b]", '('); got != "a ⁅b]" {
+		t.Errorf("before ( got %q", got)
+	}
+
+	if got := escapeMarkupSegment("[a](b)"); got != "⁅a⁆(b)" {
+		t.Errorf("segment got %q", got)
+	}
+}
+
+func TestSplitANSILines(t *testing.T) {
+	red := SGRState{Fg: ui.ColorRed}
+
+	tests := []struct {
+		name string
+		ansi string
+		want [][]ANSIRun
+	}{
+		{"empty", "", [][]ANSIRun{{}}},
+		{"one line", "\x1b[31mred\x1b[0m plain", [][]ANSIRun{{{"red", red}, {" plain", SGRState{}}}}},
+		{"a run over two lines", "\x1b[31mone\ntwo", [][]ANSIRun{{{"one", red}}, {{"two", red}}}},
+		{"blank lines", "a\n\n\x1b[31m\n", [][]ANSIRun{{{"a", SGRState{}}}, {}, {}, {}}},
+	}
+
+	for _, test := range tests {
+		if got := splitANSILines(parseANSI(test.ansi)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestTruncateANSILine(t *testing.T) {
+	red := SGRState{Fg: ui.ColorRed}
+	line := []ANSIRun{{"ab", SGRState{}}, {"世界", red}}
+
+	tests := []struct {
+		width int
+		want  []ANSIRun
+	}{
+		{6, line},
+		{10, line},
+		{5, []ANSIRun{{"ab", SGRState{}}, {"世…", red}}},
+		// The wide rune would go one over
+		{4, []ANSIRun{{"ab", SGRState{}}, {"…", red}}},
+		{3, []ANSIRun{{"ab", SGRState{}}, {"…", red}}},
+		{2, []ANSIRun{{"a…", SGRState{}}}},
+		{0, []ANSIRun{}},
+	}
+
+	for _, test := range tests {
+		if got := truncateANSILine(line, test.width); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("to %d: got %+v, want %+v", test.width, got, test.want)
+		}
+	}
+}
+
+func TestANSIParagraphKeepsPalette(t *testing.T) {
+	orange := paletteAttribute(208)
+
+	p := NewANSIParagraph()
+	p.Width = 12
+	p.Height = 4
+	p.setLines(splitANSILines(parseANSI("\x1b[38;5;208mhot\x1b[0m [x](y)\nand a longer line")), 10)
+
+	// Markup can only get close, and can't be fooled by what's in the text
+	if p.Text != "[hot](fg-red,fg-bold) ⁅x](y)\nand a lon…" {
+		t.Errorf("text is %q", p.Text)
+	}
+
+	tests := []struct {
+		mode ColorMode
+		want ui.Attribute
+	}{
+		{ColorModeTrueColor, orange},
+		{ColorMode256, orange},
+		{ColorMode16, ui.ColorRed | ui.AttrBold},
+		{ColorModeNone, ui.ColorDefault},
+	}
+
+	for _, test := range tests {
+		buf := ColorModeBufferer{bufferer: p, mode: test.mode}.Buffer()
+
+		if cell := buf.At(1, 1); cell.Ch != 'h' || cell.Fg != test.want {
+			t.Errorf("%v: got %q in %v, want %v", test.mode, cell.Ch, cell.Fg, test.want)
+		}
+	}
+
+	// Inside the border
+	buf := p.Buffer()
+	for x, want := range []rune("│and a lon…│") {
+		if cell := buf.At(x, 2); cell.Ch != want {
+			t.Errorf("%d, 2 is %q, want %q", x, cell.Ch, want)
+		}
+	}
+}
@@ -20,6 +20,8 @@ type AudioWidget struct {
 	pulse         *pulseaudio.Client
 	volumePercent uint32
 	isMuted       bool
+	// One line without a border, for small terminals
+	compact bool
 }
 
 func NewAudioWidget() *AudioWidget {
@@ -100,11 +102,44 @@ func (w *AudioWidget) update() {
 		w.widget.PercentColorHighlighted = w.widget.PercentColor
 
 		if w.isMuted {
-			w.widget.BarColor = ui.ColorRed
+			w.widget.BarColor = colorStringToAttribute(severityToColorString(SeverityCritical))
+			// Not just a different color
+			w.widget.Label = "{{percent}}% (muted)"
 		} else {
-			w.widget.BarColor = ui.ColorGreen
+			w.widget.BarColor = colorStringToAttribute(severityToColorString(SeverityOK))
 		}
 	}
+
+	if w.compact {
+		// No border to put the label on (termui would still draw it over the bar)
+		w.widget.Label = "Audio: " + w.widget.Label
+		w.widget.BorderLabel = ""
+	} else {
+		w.widget.BorderLabel = "Audio"
+	}
+}
+
+func (w *AudioWidget) setCompact(compact bool) {
+	w.compact = compact
+	w.widget.Border = !compact
+	w.widget.Height = 3
+
+	if compact {
+		w.widget.Height = 1
+	}
+
+	w.update()
+}
+
+func (w *AudioWidget) getMetrics() []Metric {
+	if w.pulse == nil {
+		return []Metric{}
+	}
+
+	return []Metric{
+		newGaugeMetric("audio", "volume_percent", "Volume of the default sink.", float64(w.volumePercent)),
+		newGaugeMetric("audio", "muted", "Whether the default sink is muted (1) or not (0).", boolToMetricValue(w.isMuted)),
+	}
 }
 
 func (w *AudioWidget) getBestSink() *pulseaudio.Object {
@@ -21,8 +21,14 @@ import (
 const BatteryUpdateIntervalSeconds = 10
 
 type BatteryWidget struct {
-	widget      *ui.Gauge
-	lastUpdated *time.Time
+	widget         *ui.Gauge
+	lastUpdated    *time.Time
+	hasBattery     bool
+	batteryPercent int
+	isCharging     bool
+	timeLeft       string
+	// One line without a border, for small terminals
+	compact bool
 }
 
 func NewBatteryWidget() *BatteryWidget {
@@ -71,23 +77,12 @@ func (w *BatteryWidget) update() {
 					log.Printf("Error reading battery percent: '%v' -- %v", lines[4], chargeErr)
 				}
 
-				battColor := percentToAttribute(batteryPercent, 0, 100, false)
+				w.hasBattery = true
+				w.batteryPercent = batteryPercent
+				w.isCharging = isCharging
+				w.timeLeft = timeLeft
 
-				if isCharging {
-					w.widget.BorderLabel = "Battery (charging)"
-					w.widget.BorderLabelFg = ui.ColorCyan | ui.AttrBold
-				} else {
-					w.widget.BorderLabel = "Battery"
-					w.widget.BorderLabelFg = battColor
-				}
-
-				w.widget.Percent = batteryPercent
-				w.widget.BarColor = battColor
-				w.widget.Label = fmt.Sprintf("%d%% (%s)", batteryPercent, timeLeft)
-				w.widget.LabelAlign = ui.AlignRight
-				w.widget.PercentColor = ui.ColorWhite | ui.AttrBold
-				//w.widget.PercentColorHighlighted = ui.ColorBlack
-				w.widget.PercentColorHighlighted = w.widget.PercentColor
+				w.refresh()
 			} else {
 				log.Printf("Not enough lines from battery command!  Output: %v", output)
 			}
@@ -97,10 +92,64 @@ func (w *BatteryWidget) update() {
 	}
 }
 
+func (w *BatteryWidget) refresh() {
+	if !w.hasBattery {
+		return
+	}
+
+	battColor := percentToAttribute(w.batteryPercent, 0, 100, false)
+
+	if w.isCharging {
+		w.widget.BorderLabel = "Battery (charging)"
+		w.widget.BorderLabelFg = ui.ColorCyan | ui.AttrBold
+	} else {
+		w.widget.BorderLabel = "Battery"
+		w.widget.BorderLabelFg = battColor
+	}
+
+	w.widget.Percent = w.batteryPercent
+	w.widget.BarColor = battColor
+	w.widget.Label = withSeverityMarker(fmt.Sprintf("%d%% (%s)", w.batteryPercent, w.timeLeft),
+		percentToSeverity(w.batteryPercent, 0, 100, false))
+	w.widget.LabelAlign = ui.AlignRight
+	w.widget.PercentColor = ui.ColorWhite | ui.AttrBold
+	//w.widget.PercentColorHighlighted = ui.ColorBlack
+	w.widget.PercentColorHighlighted = w.widget.PercentColor
+
+	if w.compact {
+		// No border to put the label on (termui would still draw it over the bar)
+		w.widget.Label = fmt.Sprintf("%v: %v", w.widget.BorderLabel, w.widget.Label)
+		w.widget.BorderLabel = ""
+	}
+}
+
+func (w *BatteryWidget) setCompact(compact bool) {
+	w.compact = compact
+	w.widget.Border = !compact
+	w.widget.Height = 3
+
+	if compact {
+		w.widget.Height = 1
+	}
+
+	w.refresh()
+}
+
 func (w *BatteryWidget) resize() {
 	// Do nothing
 }
 
+func (w *BatteryWidget) getMetrics() []Metric {
+	if !w.hasBattery {
+		return []Metric{}
+	}
+
+	return []Metric{
+		newGaugeMetric("battery", "percent", "Battery charge percentage.", float64(w.batteryPercent)),
+		newGaugeMetric("battery", "charging", "Whether the battery is charging (1) or not (0).", boolToMetricValue(w.isCharging)),
+	}
+}
+
 func (w *BatteryWidget) getUpdateInterval() time.Duration {
 	// Update every 10 seconds
 	return time.Second * 10
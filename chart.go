@@ -0,0 +1,93 @@
+package main
+
+/**
+ * Shared bits for the line charts: which stretch of time they show and how to label it.
+ */
+
+import (
+	"time"
+)
+
+////////////////////////////////////////////
+// Utility: Chart Ranges
+////////////////////////////////////////////
+
+// The key that cycles the charts through the ranges
+const ChartRangeKey = "r"
+
+type ChartRange struct {
+	Name     string
+	Duration time.Duration
+}
+
+// The first one is live, from what the widget collected itself.  The rest come out of the history.
+var ChartRanges = []ChartRange{
+	{"10m", 10 * time.Minute},
+	{"1h", time.Hour},
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+}
+
+// Only the live range without history to read from
+func nextChartRange(current int) int {
+	if metricHistory == nil {
+		return 0
+	}
+
+	return (current + 1) % len(ChartRanges)
+}
+
+// Times of day while they fit in a day, dates after that
+func formatChartLabel(t time.Time, span time.Duration) string {
+	if span <= 24*time.Hour {
+		return t.Format("15:04")
+	}
+
+	return t.Format("Jan 2")
+}
+
+////////////////////////////////////////////
+// Utility: Chart Data
+////////////////////////////////////////////
+
+// What a line chart shows over a range.  Peaks are only there for ranges read from the history.
+type ChartData struct {
+	Values []float64
+	Peaks  []float64
+	Labels []string
+}
+
+// The live range comes from the points the widget kept itself, the others from the history's rollups of key.  At most
+// slots points either way.
+func buildChartData(live *TimeSeries, key string, chartRange int, slots int) ChartData {
+	r := ChartRanges[chartRange]
+	now := time.Now()
+
+	if chartRange == 0 {
+		points := live.since(now.Add(-r.Duration))
+		if len(points) > slots {
+			points = points[len(points)-slots:]
+		}
+
+		return ChartData{
+			Values: pointValues(points),
+			Labels: pointLabels(points, r.Duration),
+		}
+	}
+
+	rollups := queryHistoryRollups(key, now.Add(-r.Duration), now, slots)
+
+	data := ChartData{
+		Values: make([]float64, len(rollups)),
+		Peaks:  make([]float64, len(rollups)),
+		Labels: make([]string, len(rollups)),
+	}
+
+	for i, rollup := range rollups {
+		data.Values[i] = rollup.avg()
+		data.Peaks[i] = rollup.Max
+		data.Labels[i] = formatChartLabel(rollup.Timestamp, r.Duration)
+	}
+
+	return data
+}
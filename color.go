@@ -0,0 +1,211 @@
+package main
+
+/**
+ * What colors the terminal can show, and the closest thing to a color when it can't show that one.
+ */
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	ui "github.com/gizak/termui"
+	termbox "github.com/nsf/termbox-go"
+)
+
+////////////////////////////////////////////
+// Utility: Color Modes
+////////////////////////////////////////////
+
+type ColorMode int
+
+const (
+	ColorModeNone ColorMode = iota
+	ColorMode16
+	ColorMode256
+	// Only the ANSI output gets more than 256 colors, termui attributes can't hold any more than that
+	ColorModeTrueColor
+)
+
+var ColorModeNames = []string{"none", "16", "256", "truecolor"}
+
+func (m ColorMode) String() string {
+	return ColorModeNames[m]
+}
+
+func parseColorMode(name string) (ColorMode, bool) {
+	for i, n := range ColorModeNames {
+		if n == name {
+			return ColorMode(i), true
+		}
+	}
+
+	return ColorModeNone, false
+}
+
+// The usual guesses: NO_COLOR (https://no-color.org) turns it off, COLORTERM says truecolor and TERM says 256 colors
+func detectColorMode(term string, colorTerm string, noColor string) ColorMode {
+	switch {
+	case len(noColor) > 0 || term == "dumb":
+		return ColorModeNone
+	case colorTerm == "truecolor" || colorTerm == "24bit":
+		return ColorModeTrueColor
+	case strings.Contains(term, "256color"):
+		return ColorMode256
+	}
+
+	return ColorMode16
+}
+
+// The -color setting, unless that's auto, in which case it's whatever the terminal says it can do
+func colorModeForTerminal(term string, colorTerm string, noColor string) ColorMode {
+	if mode, ok := parseColorMode(getColorModeSetting()); ok {
+		return mode
+	}
+
+	return detectColorMode(term, colorTerm, noColor)
+}
+
+// termbox starts out with only the 8 colors
+func initTerminalColors(mode ColorMode) {
+	if mode >= ColorMode256 {
+		termbox.SetOutputMode(termbox.Output256)
+	}
+}
+
+////////////////////////////////////////////
+// Utility: Nearest Colors
+////////////////////////////////////////////
+
+// Like "#cd3131"
+func hexToRGB(hex string) (int, int, int) {
+	value, err := strconv.ParseUint(strings.TrimPrefix(hex, "#"), 16, 32)
+	if err != nil {
+		return 0, 0, 0
+	}
+
+	return int(value>>16) & 0xFF, int(value>>8) & 0xFF, int(value) & 0xFF
+}
+
+// The same palette the screenshots and the web page use
+func xterm256ToRGB(n int) (int, int, int) {
+	return hexToRGB(xterm256ToHex(n))
+}
+
+// Weighted for how eyes see it, which picks better than straight distance (https://www.compuphase.com/cmetric.htm)
+func colorDistance(r1 int, g1 int, b1 int, r2 int, g2 int, b2 int) float64 {
+	meanRed := float64(r1+r2) / 2
+	dr, dg, db := float64(r1-r2), float64(g1-g2), float64(b1-b2)
+
+	return math.Sqrt((2+meanRed/256)*dr*dr + 4*dg*dg + (2+(255-meanRed)/256)*db*db)
+}
+
+// Out of the 6x6x6 cube and the grays, leaving out the first 16 since every terminal has its own idea of those
+func rgbToXterm256(r int, g int, b int) int {
+	levels := []int{0, 95, 135, 175, 215, 255}
+
+	nearestLevel := func(v int) int {
+		best := 0
+
+		for i, level := range levels {
+			if math.Abs(float64(v-level)) < math.Abs(float64(v-levels[best])) {
+				best = i
+			}
+		}
+
+		return best
+	}
+
+	cr, cg, cb := nearestLevel(r), nearestLevel(g), nearestLevel(b)
+
+	// Grays go from 8 to 238 in steps of 10
+	gray := ((r+g+b)/3 - 3) / 10
+	if gray < 0 {
+		gray = 0
+	} else if gray > 23 {
+		gray = 23
+	}
+
+	grayLevel := 8 + 10*gray
+
+	if colorDistance(r, g, b, grayLevel, grayLevel, grayLevel) < colorDistance(r, g, b, levels[cr], levels[cg], levels[cb]) {
+		return 232 + gray
+	}
+
+	return 16 + 36*cr + 6*cg + cb
+}
+
+// One of the 8 colors, bold for the bright half
+func rgbToAttribute16(r int, g int, b int) ui.Attribute {
+	best := 0
+	bestDistance := math.MaxFloat64
+
+	for n := 0; n < 16; n++ {
+		pr, pg, pb := xterm256ToRGB(n)
+
+		if distance := colorDistance(r, g, b, pr, pg, pb); distance < bestDistance {
+			best = n
+			bestDistance = distance
+		}
+	}
+
+	if best < 8 {
+		return ui.Attribute(best + 1)
+	}
+
+	return ui.Attribute(best-7) | ui.AttrBold
+}
+
+// The color part of attr, changed to something the mode can show.  Flags (like bold) stay.
+func adaptAttribute(attr ui.Attribute, mode ColorMode) ui.Attribute {
+	color := int(attr & AttributeColorMask)
+	flags := attr &^ AttributeColorMask
+
+	switch {
+	case mode == ColorModeNone:
+		return flags
+	case mode == ColorMode16 && color > 8:
+		// 256 color mode is off by one, like termbox
+		return flags | rgbToAttribute16(xterm256ToRGB(color-1))
+	}
+
+	return attr
+}
+
+////////////////////////////////////////////
+// Utility: Color Mode Bufferer
+////////////////////////////////////////////
+
+// Draws something with only the colors the terminal can show
+type ColorModeBufferer struct {
+	bufferer ui.Bufferer
+	mode     ColorMode
+}
+
+func (c ColorModeBufferer) Buffer() ui.Buffer {
+	buf := c.bufferer.Buffer()
+
+	for p, cell := range buf.CellMap {
+		if c.mode == ColorModeNone && cell.Bg&AttributeColorMask != ui.ColorDefault {
+			// Gauge bars and selected rows are nothing but background, so they'd disappear
+			cell.Fg |= ui.AttrReverse
+		}
+
+		cell.Fg = adaptAttribute(cell.Fg, c.mode)
+		cell.Bg = adaptAttribute(cell.Bg, c.mode)
+		buf.CellMap[p] = cell
+	}
+
+	return buf
+}
+
+// For ui.Render
+func adaptColors(mode ColorMode, bs ...ui.Bufferer) []ui.Bufferer {
+	adapted := make([]ui.Bufferer, len(bs))
+
+	for i, b := range bs {
+		adapted[i] = ColorModeBufferer{bufferer: b, mode: mode}
+	}
+
+	return adapted
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+
+	ui "github.com/gizak/termui"
+)
+
+func TestRgbToXterm256(t *testing.T) {
+	tests := []struct {
+		r, g, b int
+		want    int
+	}{
+		{255, 0, 0, 196},
+		{255, 128, 0, 208},
+		{95, 135, 175, 67},
+		{0, 0, 0, 16},
+		{255, 255, 255, 231},
+		// Closer to a gray than to anything in the cube
+		{128, 128, 128, 244},
+		{20, 20, 20, 233},
+		{238, 238, 238, 255},
+	}
+
+	for _, test := range tests {
+		if got := rgbToXterm256(test.r, test.g, test.b); got != test.want {
+			t.Errorf("%d,%d,%d: got %d, want %d", test.r, test.g, test.b, got, test.want)
+		}
+	}
+}
+
+func TestAdaptAttribute(t *testing.T) {
+	red196 := paletteAttribute(196)
+
+	tests := []struct {
+		name string
+		attr ui.Attribute
+		mode ColorMode
+		want ui.Attribute
+	}{
+		{"no color keeps flags", ui.ColorRed | ui.AttrBold, ColorModeNone, ui.AttrBold},
+		{"no color for a palette color", red196 | ui.AttrUnderline, ColorModeNone, ui.AttrUnderline},
+		{"8 colors stay", ui.ColorRed, ColorMode16, ui.ColorRed},
+		{"default stays", ui.ColorDefault, ColorMode16, ui.ColorDefault},
+		{"red", red196, ColorMode16, ui.ColorRed},
+		{"red keeps underline", red196 | ui.AttrUnderline, ColorMode16, ui.ColorRed | ui.AttrUnderline},
+		{"dark blue", paletteAttribute(18), ColorMode16, ui.ColorBlue},
+		{"gray", paletteAttribute(244), ColorMode16, ui.ColorBlack | ui.AttrBold},
+		{"the bright 8 are bold", paletteAttribute(10), ColorMode16, ui.ColorGreen | ui.AttrBold},
+		{"256 colors kept", red196, ColorMode256, red196},
+		{"truecolor kept", red196 | ui.AttrBold, ColorModeTrueColor, red196 | ui.AttrBold},
+	}
+
+	for _, test := range tests {
+		if got := adaptAttribute(test.attr, test.mode); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDetectColorMode(t *testing.T) {
+	tests := []struct {
+		term      string
+		colorTerm string
+		noColor   string
+		want      ColorMode
+	}{
+		{"", "", "", ColorMode16},
+		{"xterm", "", "", ColorMode16},
+		{"xterm-256color", "", "", ColorMode256},
+		{"screen-256color", "", "", ColorMode256},
+		{"xterm", "truecolor", "", ColorModeTrueColor},
+		{"xterm-256color", "24bit", "", ColorModeTrueColor},
+		{"xterm", "yes", "", ColorMode16},
+		{"dumb", "truecolor", "", ColorModeNone},
+		{"xterm-256color", "truecolor", "1", ColorModeNone},
+	}
+
+	for _, test := range tests {
+		if got := detectColorMode(test.term, test.colorTerm, test.noColor); got != test.want {
+			t.Errorf("TERM=%q COLORTERM=%q NO_COLOR=%q: got %v, want %v", test.term, test.colorTerm, test.noColor,
+				got, test.want)
+		}
+	}
+}
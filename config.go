@@ -1,14 +1,19 @@
 package main
 
 /**
- * Load configuration.  Right now that's all from the environment variables.  Maybe someday do something better?
+ * Load configuration.  Mostly that's from the environment variables, with a few command line flags.  Maybe someday do
+ * something better?
  */
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 ////////////////////////////////////////////
@@ -153,3 +158,415 @@ func GetWeatherLocation() string {
 		return loc
 	}
 }
+
+////////////////////////////////////////////
+// CPU Chart
+////////////////////////////////////////////
+
+var cpuSeriesFlag = flag.String("cpu-series", getEnvOrDefault("SYSDASH_CPU_SERIES", "cpu,load1"),
+	"What the CPU chart starts out plotting: any of cpu, load1, load5 and load15, comma separated (also SYSDASH_CPU_SERIES)")
+
+func GetCPUChartSeries() []string {
+	return parseCPUChartSeries(*cpuSeriesFlag)
+}
+
+////////////////////////////////////////////
+// Metrics Exporter
+////////////////////////////////////////////
+
+var metricsListenFlag = flag.String("listen", os.ExpandEnv("$SYSDASH_METRICS_LISTEN"),
+	"Address to serve Prometheus metrics on, like ':9199' (also SYSDASH_METRICS_LISTEN)")
+
+// Empty means don't serve metrics
+func GetMetricsListenAddress() string {
+	return *metricsListenFlag
+}
+
+////////////////////////////////////////////
+// Metrics Push
+////////////////////////////////////////////
+
+var metricsPushFlag = flag.String("push", os.ExpandEnv("$SYSDASH_PUSH_TARGET"),
+	"Where to push metrics after each update, like 'udp://localhost:8125' (also SYSDASH_PUSH_TARGET)")
+var metricsPushFormatFlag = flag.String("push-format", getEnvOrDefault("SYSDASH_PUSH_FORMAT", "statsd"),
+	"Format for pushed metrics: statsd, graphite or influx (also SYSDASH_PUSH_FORMAT)")
+var metricsPushPrefixFlag = flag.String("push-prefix", getEnvOrDefault("SYSDASH_PUSH_PREFIX", "sysdash"),
+	"Prefix for pushed metric names (also SYSDASH_PUSH_PREFIX)")
+var metricsPushTagsFlag = flag.String("push-tags", os.ExpandEnv("$SYSDASH_PUSH_TAGS"),
+	"Extra tags for pushed metrics, like 'team=infra,env=dev' (also SYSDASH_PUSH_TAGS)")
+
+func getEnvOrDefault(name string, defaultValue string) string {
+	value := os.ExpandEnv("$" + name)
+
+	if len(value) <= 0 {
+		return defaultValue
+	} else {
+		return value
+	}
+}
+
+// Empty means don't push
+func GetMetricsPushTarget() string {
+	return *metricsPushFlag
+}
+
+func GetMetricsPushFormat() string {
+	return *metricsPushFormatFlag
+}
+
+func GetMetricsPushPrefix() string {
+	return *metricsPushPrefixFlag
+}
+
+// Always includes the hostname
+func GetMetricsPushTags() []MetricLabel {
+	hostName, _ := getHostname()
+	tags := []MetricLabel{{Name: "host", Value: hostName}}
+
+	if len(*metricsPushTagsFlag) <= 0 {
+		return tags
+	}
+
+	// Current format is name=value,name=value...
+	for _, tag := range strings.Split(*metricsPushTagsFlag, ",") {
+		parts := strings.SplitN(tag, "=", 2)
+
+		if len(parts) != 2 {
+			log.Printf("Error parsing push tag '%v'", tag)
+		} else {
+			tags = append(tags, MetricLabel{Name: strings.TrimSpace(parts[0]), Value: strings.TrimSpace(parts[1])})
+		}
+	}
+
+	return tags
+}
+
+////////////////////////////////////////////
+// MQTT
+////////////////////////////////////////////
+
+var mqttBrokerFlag = flag.String("mqtt", os.ExpandEnv("$SYSDASH_MQTT_BROKER"),
+	"MQTT broker to publish widget states to, like 'tcp://localhost:1883' (also SYSDASH_MQTT_BROKER)")
+var mqttPrefixFlag = flag.String("mqtt-prefix", getEnvOrDefault("SYSDASH_MQTT_PREFIX", "sysdash"),
+	"Topic prefix for widget states (also SYSDASH_MQTT_PREFIX)")
+var mqttDiscoveryFlag = flag.String("mqtt-discovery", os.ExpandEnv("$SYSDASH_MQTT_DISCOVERY"),
+	"Home Assistant discovery prefix, like 'homeassistant', empty to disable (also SYSDASH_MQTT_DISCOVERY)")
+
+// Empty means don't publish
+func GetMQTTBroker() string {
+	return *mqttBrokerFlag
+}
+
+func GetMQTTPrefix() string {
+	return *mqttPrefixFlag
+}
+
+func GetMQTTDiscoveryPrefix() string {
+	return *mqttDiscoveryFlag
+}
+
+////////////////////////////////////////////
+// Web Dashboard
+////////////////////////////////////////////
+
+var webDashboardFlag = flag.String("http", os.ExpandEnv("$SYSDASH_HTTP_LISTEN"),
+	"Address to serve the web dashboard and JSON API on, like ':8080' (also SYSDASH_HTTP_LISTEN)")
+
+// Empty means don't serve it
+func GetWebDashboardAddress() string {
+	return *webDashboardFlag
+}
+
+////////////////////////////////////////////
+// Commands
+////////////////////////////////////////////
+
+var Commands = []string{"agent", "attach", "hosts", "serve-ssh", "statusline"}
+
+// Parses the flags, returns the command (empty for the plain dashboard).  Flags can come before or after the command.
+func parseCommandLine() string {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [%s] [flags]\n", os.Args[0], strings.Join(Commands, "|"))
+		flag.PrintDefaults()
+
+		for _, command := range Commands {
+			if commandFlagSet, ok := commandFlags[command]; ok {
+				fmt.Fprintf(flag.CommandLine.Output(), "\nFlags for %s:\n", command)
+				commandFlagSet.SetOutput(flag.CommandLine.Output())
+				commandFlagSet.PrintDefaults()
+			}
+		}
+	}
+
+	flag.Parse()
+
+	command := flag.Arg(0)
+
+	if len(command) > 0 {
+		flags := flag.CommandLine
+
+		if commandFlagSet, ok := commandFlags[command]; ok {
+			// The command's own flags win over the global ones with the same name (like serve-ssh's -listen)
+			flags = flag.NewFlagSet(command, flag.ExitOnError)
+			flags.Usage = flag.Usage
+
+			commandFlagSet.VisitAll(func(f *flag.Flag) {
+				flags.Var(f.Value, f.Name, f.Usage)
+			})
+
+			flag.VisitAll(func(f *flag.Flag) {
+				if flags.Lookup(f.Name) == nil {
+					flags.Var(f.Value, f.Name, f.Usage)
+				}
+			})
+		}
+
+		flags.Parse(flag.Args()[1:])
+
+		if flags.NArg() > 0 {
+			fmt.Fprintf(os.Stderr, "Unexpected arguments: %v\n", flags.Args())
+			flag.Usage()
+			os.Exit(2)
+		}
+	}
+
+	return command
+}
+
+// Flags that only mean something after their command
+var commandFlags = map[string]*flag.FlagSet{}
+
+func newCommandFlagSet(command string) *flag.FlagSet {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	commandFlags[command] = flags
+
+	return flags
+}
+
+////////////////////////////////////////////
+// Agent
+////////////////////////////////////////////
+
+var agentSocketFlag = flag.String("socket", getEnvOrDefault("SYSDASH_AGENT_SOCKET", defaultAgentSocketPath()),
+	"Unix socket the agent listens on and attach connects to (also SYSDASH_AGENT_SOCKET)")
+
+func defaultAgentSocketPath() string {
+	if runtimeDir := os.ExpandEnv("$XDG_RUNTIME_DIR"); len(runtimeDir) > 0 {
+		return filepath.Join(runtimeDir, "sysdash.sock")
+	}
+
+	return filepath.Join(os.TempDir(), fmt.Sprintf("sysdash-%d.sock", os.Getuid()))
+}
+
+func GetAgentSocketPath() string {
+	return *agentSocketFlag
+}
+
+////////////////////////////////////////////
+// One-shot Output
+////////////////////////////////////////////
+
+var onceFlag = flag.Bool("once", false, "Collect everything once, print it and exit")
+var onceFormatFlag = flag.String("format", "json", "Output format for -once: json, or a screenshot as ansi, html or svg")
+
+func RunOnce() bool {
+	return *onceFlag
+}
+
+func GetOnceFormat() string {
+	return *onceFormatFlag
+}
+
+////////////////////////////////////////////
+// Remote Hosts
+////////////////////////////////////////////
+
+const DefaultRemoteCommand = "sysdash --once --format=json"
+
+var remoteHostsFlag = flag.String("hosts", os.ExpandEnv("$SYSDASH_REMOTE_HOSTS"),
+	"Hosts to show with the hosts command, like 'devbox1,user@devbox2' (also SYSDASH_REMOTE_HOSTS)")
+var remoteCommandFlag = flag.String("remote-command", getEnvOrDefault("SYSDASH_REMOTE_COMMAND", DefaultRemoteCommand),
+	"Command run over SSH on each remote host, it has to print one JSON state (also SYSDASH_REMOTE_COMMAND)")
+
+func GetRemoteHosts() []string {
+	hosts := make([]string, 0)
+
+	// Current format is host,host,host...
+	for _, host := range strings.Split(*remoteHostsFlag, ",") {
+		host = strings.TrimSpace(host)
+
+		if len(host) > 0 {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
+
+func GetRemoteCommand() string {
+	return *remoteCommandFlag
+}
+
+////////////////////////////////////////////
+// SSH Server
+////////////////////////////////////////////
+
+var sshFlags = newCommandFlagSet("serve-ssh")
+
+var sshListenFlag = sshFlags.String("listen", getEnvOrDefault("SYSDASH_SSH_LISTEN", ":2222"),
+	"Address to accept SSH connections on (also SYSDASH_SSH_LISTEN)")
+var sshAuthorizedKeysFlag = sshFlags.String("authorized-keys",
+	getEnvOrDefault("SYSDASH_SSH_AUTHORIZED_KEYS", filepath.Join(os.ExpandEnv("$HOME"), ".ssh", "authorized_keys")),
+	"Public keys allowed to connect, in authorized_keys format (also SYSDASH_SSH_AUTHORIZED_KEYS)")
+var sshHostKeyFlag = sshFlags.String("host-key",
+	getEnvOrDefault("SYSDASH_SSH_HOST_KEY", filepath.Join(getConfigDir(), "ssh_host_ed25519_key")),
+	"Private host key, created if it doesn't exist (also SYSDASH_SSH_HOST_KEY)")
+
+// $XDG_CONFIG_HOME/sysdash
+func getConfigDir() string {
+	configHome := getEnvOrDefault("XDG_CONFIG_HOME", filepath.Join(os.ExpandEnv("$HOME"), ".config"))
+
+	return filepath.Join(configHome, "sysdash")
+}
+
+func GetSSHListenAddress() string {
+	return *sshListenFlag
+}
+
+func GetSSHAuthorizedKeysPath() string {
+	return *sshAuthorizedKeysFlag
+}
+
+func GetSSHHostKeyPath() string {
+	return *sshHostKeyFlag
+}
+
+////////////////////////////////////////////
+// Status Line
+////////////////////////////////////////////
+
+var statusLineFlags = newCommandFlagSet("statusline")
+
+var statusLineTargetFlag = statusLineFlags.String("target", getEnvOrDefault("SYSDASH_STATUSLINE_TARGET", "tmux"),
+	"Status bar to write for: tmux, i3bar or waybar (also SYSDASH_STATUSLINE_TARGET)")
+
+func GetStatusLineTarget() string {
+	return *statusLineTargetFlag
+}
+
+////////////////////////////////////////////
+// History
+////////////////////////////////////////////
+
+const DefaultHistoryRetention = 7 * 24 * time.Hour
+
+// Off unless asked for, it's a write per metric per update
+var historyFlag = flag.String("history", getEnvOrDefault("SYSDASH_HISTORY", ""),
+	"File to keep metric history in, like ~/.local/share/sysdash/history.db, empty to keep none (also SYSDASH_HISTORY)")
+var historyRetentionFlag = flag.String("history-retention",
+	getEnvOrDefault("SYSDASH_HISTORY_RETENTION", DefaultHistoryRetention.String()),
+	"How long to keep metric history, like '72h' (also SYSDASH_HISTORY_RETENTION)")
+
+// $XDG_DATA_HOME/sysdash
+func getDataDir() string {
+	dataHome := getEnvOrDefault("XDG_DATA_HOME", filepath.Join(os.ExpandEnv("$HOME"), ".local", "share"))
+
+	return filepath.Join(dataHome, "sysdash")
+}
+
+// Empty means don't keep history
+func GetHistoryPath() string {
+	return *historyFlag
+}
+
+func GetHistoryRetention() time.Duration {
+	retention, err := time.ParseDuration(*historyRetentionFlag)
+
+	if err != nil || retention <= 0 {
+		log.Printf("Failed to parse history retention '%v', using %v: %v", *historyRetentionFlag, DefaultHistoryRetention, err)
+		return DefaultHistoryRetention
+	}
+
+	return retention
+}
+
+////////////////////////////////////////////
+// Charts
+////////////////////////////////////////////
+
+var chartsFlag = flag.String("charts",
+	getEnvOrDefault("SYSDASH_CHARTS", filepath.Join(getConfigDir(), "charts.json")),
+	"JSON file of extra charts to show (also SYSDASH_CHARTS)")
+
+func GetChartsPath() string {
+	return *chartsFlag
+}
+
+////////////////////////////////////////////
+// Screenshots
+////////////////////////////////////////////
+
+var screenshotDirFlag = flag.String("screenshot-dir",
+	getEnvOrDefault("SYSDASH_SCREENSHOT_DIR", filepath.Join(getDataDir(), "screenshots")),
+	"Where the screenshot key saves to (also SYSDASH_SCREENSHOT_DIR)")
+var screenshotFormatFlag = flag.String("screenshot-format", getEnvOrDefault("SYSDASH_SCREENSHOT_FORMAT", "svg"),
+	"What the screenshot key saves: ansi, html or svg (also SYSDASH_SCREENSHOT_FORMAT)")
+
+func GetScreenshotDir() string {
+	return *screenshotDirFlag
+}
+
+func GetScreenshotFormat() string {
+	if !isScreenshotFormat(*screenshotFormatFlag) {
+		log.Printf("Unknown screenshot format '%v', using svg", *screenshotFormatFlag)
+		return "svg"
+	}
+
+	return *screenshotFormatFlag
+}
+
+////////////////////////////////////////////
+// Colors
+////////////////////////////////////////////
+
+var colorFlag = flag.String("color", getEnvOrDefault("SYSDASH_COLOR", "auto"),
+	"Colors to draw with: auto (from NO_COLOR, COLORTERM and TERM), none, 16, 256 or truecolor (also SYSDASH_COLOR)")
+
+func getColorModeSetting() string {
+	if _, ok := parseColorMode(*colorFlag); !ok && *colorFlag != "auto" {
+		log.Printf("Unknown color mode '%v', using auto", *colorFlag)
+		return "auto"
+	}
+
+	return *colorFlag
+}
+
+// For the terminal sysdash is running in
+func GetColorMode() ColorMode {
+	return colorModeForTerminal(os.Getenv("TERM"), os.Getenv("COLORTERM"), os.Getenv("NO_COLOR"))
+}
+
+var themeFlag = flag.String("theme", getEnvOrDefault("SYSDASH_THEME", ThemeDefault),
+	"Status colors: default, or high-contrast for no red against green (also SYSDASH_THEME)")
+
+var symbolsFlag = flag.String("symbols", getEnvOrDefault("SYSDASH_SYMBOLS", SymbolsAuto),
+	"OK/WARN/!! next to status colors: auto (with no colors or the high-contrast theme), on or off (also SYSDASH_SYMBOLS)")
+
+func GetTheme() string {
+	if _, ok := ThemePalettes[*themeFlag]; !ok {
+		log.Printf("Unknown theme '%v', using %v", *themeFlag, ThemeDefault)
+		return ThemeDefault
+	}
+
+	return *themeFlag
+}
+
+func GetSymbolsSetting() string {
+	switch *symbolsFlag {
+	case SymbolsAuto, SymbolsOn, SymbolsOff:
+		return *symbolsFlag
+	}
+
+	log.Printf("Unknown symbols setting '%v', using %v", *symbolsFlag, SymbolsAuto)
+	return SymbolsAuto
+}
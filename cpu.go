@@ -6,51 +6,237 @@ package main
 
 import (
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	linuxproc "github.com/c9s/goprocinfo/linux"
 	ui "github.com/gizak/termui"
 )
 
+////////////////////////////////////////////
+// Utility: CPU Usage
+////////////////////////////////////////////
+
+// Turns one line of /proc/stat (which counts jiffies since boot) into how busy it was since the last reading
+type CPUUsageTracker struct {
+	busyJiffies  *TimeSeries
+	totalJiffies *TimeSeries
+}
+
+func NewCPUUsageTracker() *CPUUsageTracker {
+	return &CPUUsageTracker{
+		busyJiffies:  NewTimeSeries(2),
+		totalJiffies: NewTimeSeries(2),
+	}
+}
+
+// From 0 to 1, false if no time went by
+func (t *CPUUsageTracker) add(now time.Time, stat linuxproc.CPUStat) (float64, bool) {
+	// from: https://stackoverflow.com/a/23376195
+	idle := stat.Idle + stat.IOWait
+	nonIdle := stat.User + stat.Nice + stat.System + stat.IRQ + stat.SoftIRQ + stat.Steal
+
+	t.busyJiffies.add(now, float64(nonIdle))
+	t.totalJiffies.add(now, float64(idle+nonIdle))
+
+	//  differentiate: actual value minus the previous one (or since boot, the first time)
+	busyd, _ := t.busyJiffies.delta()
+	totald, hasPrevious := t.totalJiffies.delta()
+
+	if !hasPrevious {
+		busyd, totald = float64(nonIdle), float64(idle+nonIdle)
+	}
+
+	if totald <= 0 {
+		return 0, false
+	}
+
+	return busyd / totald, true
+}
+
+////////////////////////////////////////////
+// Utility: Per-Core Usage
+////////////////////////////////////////////
+
+// Per-core entries get a label, until they'd take more rows than this and it switches to a heatmap
+const CPUCoresMaxLabeledRows = 2
+
+// Heatmap cells go in groups this big, so it's easier to count along to a core
+const CPUCoresHeatmapGroup = 8
+
+// Taller is busier, so it doesn't only come down to color
+var CPUCoreBarCharacters = []rune("▁▂▃▄▅▆▇█")
+
+func coreBar(percent float64) string {
+	level := int(percent * float64(len(CPUCoreBarCharacters)))
+
+	if level >= len(CPUCoreBarCharacters) {
+		level = len(CPUCoreBarCharacters) - 1
+	} else if level < 0 {
+		level = 0
+	}
+
+	return string(CPUCoreBarCharacters[level])
+}
+
+func coreColorString(percent float64) string {
+	return percentToAttributeString(int(100*percent), 0, 100, true)
+}
+
+// Like " 3 ▆ 87%", the bar and the number in the core's color
+func buildCoreEntry(core int, percent float64, numberWidth int) string {
+	return fmt.Sprintf("%*d [%s %3.0f%%](%s)", numberWidth, core, coreBar(percent), 100*percent, coreColorString(percent))
+}
+
+// A labeled entry per core while they fit in a couple of rows, a heatmap cell per core after that
+func buildCoreLines(percents []float64, width int) []string {
+	if len(percents) == 0 || width <= 0 {
+		return []string{}
+	}
+
+	numberWidth := len(strconv.Itoa(len(percents) - 1))
+	entryWidth := numberWidth + displayWidth(" ▆ 100%")
+
+	perRow := (width + 2) / (entryWidth + 2)
+	if perRow < 1 {
+		perRow = 1
+	}
+
+	lines := make([]string, 0)
+
+	if (len(percents)+perRow-1)/perRow <= CPUCoresMaxLabeledRows {
+		for start := 0; start < len(percents); start += perRow {
+			entries := make([]string, 0, perRow)
+
+			for core := start; core < start+perRow && core < len(percents); core++ {
+				entries = append(entries, buildCoreEntry(core, percents[core], numberWidth))
+			}
+
+			lines = append(lines, strings.Join(entries, "  "))
+		}
+
+		return lines
+	}
+
+	groupsPerRow := (width + 1) / (CPUCoresHeatmapGroup + 1)
+	if groupsPerRow < 1 {
+		groupsPerRow = 1
+	}
+
+	perRow = groupsPerRow * CPUCoresHeatmapGroup
+	var line strings.Builder
+
+	for core, percent := range percents {
+		if core > 0 && core%perRow == 0 {
+			lines = append(lines, line.String())
+			line.Reset()
+		} else if core > 0 && core%CPUCoresHeatmapGroup == 0 {
+			line.WriteString(" ")
+		}
+
+		line.WriteString(fmt.Sprintf("[%s](%s)", coreBar(percent), coreColorString(percent)))
+	}
+
+	return append(lines, line.String())
+}
+
+// The one to look at when a single-threaded something is pegging a core
+func busiestCore(percents []float64) (int, float64) {
+	busiest := -1
+	highest := math.Inf(-1)
+
+	for core, percent := range percents {
+		if percent > highest {
+			busiest, highest = core, percent
+		}
+	}
+
+	return busiest, highest
+}
+
 ////////////////////////////////////////////
 // Widget: CPU
 ////////////////////////////////////////////
 
 const CPUWidgetUpdateInterval = 7 * time.Second
 
+// About two hours of updates, more than the widest live chart needs
+const CPUWidgetHistoryCapacity = 1024
+
+const (
+	CPUWidgetHeight        = 20
+	CPUWidgetCompactHeight = 10
+)
+
+// The CPU time breakdown under the chart, border included
+const (
+	CPUTimeChartHeight        = 8
+	CPUTimeChartCompactHeight = 5
+)
+
 type CPUWidget struct {
+	// The chart, with where the time went and the cores under it
+	column      *ui.Row
 	widget      *ui.LineChart
+	times       *ui.Paragraph
+	cores       *ui.Paragraph
 	lastUpdated *time.Time
 
-	numProcessors      int
-	lastStat           linuxproc.CPUStat
-	curStat            linuxproc.CPUStat
-	cpuPercent         float64
-	loadLast1Min       []float64
-	loadLast5Min       []float64
-	timestamps         []string
-	mostRecent1MinLoad float64
-	mostRecent5MinLoad float64
+	numProcessors       int
+	cpuPercent          float64
+	cpuUsage            *CPUUsageTracker
+	cpuPercents         *TimeSeries
+	coreUsage           []*CPUUsageTracker
+	corePercents        []float64
+	timeBreakdown       *CPUTimeBreakdown
+	load1Min            *TimeSeries
+	load5Min            *TimeSeries
+	mostRecent1MinLoad  float64
+	mostRecent5MinLoad  float64
+	mostRecent15MinLoad float64
+	load15Min           *TimeSeries
+	// Index into ChartRanges
+	chartRange int
+	// Names from CPUChartSeriesList
+	series []string
+	scale  CPUChartScale
 }
 
 func NewCPUWidget() *CPUWidget {
 	// Create base element
 	e := ui.NewLineChart()
-	e.Height = 20
+	e.Height = CPUWidgetHeight
 	e.Border = true
 	e.PaddingTop = 1
-	e.LineColor["cpu"] = ui.ColorBlue | ui.AttrBold
 	e.AxesColor = ui.ColorYellow
 
+	times := ui.NewParagraph("")
+	times.Height = CPUTimeChartHeight
+
+	cores := ui.NewParagraph("")
+	cores.BorderLabel = "Cores"
+	cores.Height = 3
+
 	// Create widget
 	w := &CPUWidget{
-		widget:       e,
-		cpuPercent:   0,
-		loadLast1Min: make([]float64, 0),
-		loadLast5Min: make([]float64, 0),
-		timestamps:   make([]string, 0),
+		column:        ui.NewCol(12, 0, e, times, cores),
+		widget:        e,
+		times:         times,
+		cores:         cores,
+		cpuPercent:    0,
+		cpuUsage:      NewCPUUsageTracker(),
+		cpuPercents:   NewTimeSeries(CPUWidgetHistoryCapacity),
+		timeBreakdown: NewCPUTimeBreakdown(CPUWidgetHistoryCapacity),
+		load1Min:      NewTimeSeries(CPUWidgetHistoryCapacity),
+		load5Min:      NewTimeSeries(CPUWidgetHistoryCapacity),
+		load15Min:     NewTimeSeries(CPUWidgetHistoryCapacity),
+		series:        GetCPUChartSeries(),
+		scale:         CPUChartScalePercent,
 	}
 
+	w.loadHistory()
 	w.update()
 	w.resize()
 
@@ -58,60 +244,211 @@ func NewCPUWidget() *CPUWidget {
 }
 
 func (w *CPUWidget) getGridWidget() ui.GridBufferer {
-	return w.widget
+	return w.column
 }
 
 func (w *CPUWidget) update() {
 	if shouldUpdate(w) {
 		w.loadProcessorStats()
+		w.refreshChart()
+		w.refreshTimes()
+		w.refreshCores()
+	}
+}
+
+func (w *CPUWidget) refreshTimes() {
+	width := paragraphTextWidth(w.times)
+	height := w.times.Height - 2
+
+	w.times.Text = strings.Join(buildStackedChartLines(w.timeBreakdown.lastN(width), width, height), "\n")
+	w.times.BorderLabel = "Time: " + buildCPUTimeLegend(w.timeBreakdown.latest())
+}
+
+func (w *CPUWidget) refreshCores() {
+	lines := buildCoreLines(w.corePercents, paragraphTextWidth(w.cores))
+	w.cores.Text = strings.Join(lines, "\n")
+	fitParagraphHeight(w.cores, len(lines), 3)
+
+	w.cores.BorderLabel = "Cores"
+	if core, percent := busiestCore(w.corePercents); core >= 0 {
+		busiest := withSeverityMarker(fmt.Sprintf("busiest: %d at %0.0f%%", core, 100*percent),
+			percentToSeverity(int(100*percent), 0, 100, true))
+		w.cores.BorderLabel = fmt.Sprintf("Cores ── [%v](%s)", busiest, coreColorString(percent))
+	}
+}
+
+// What the widget collected itself for a series
+func (w *CPUWidget) getLiveSeries(series CPUChartSeries) *TimeSeries {
+	switch series.Name {
+	case "load1":
+		return w.load1Min
+	case "load5":
+		return w.load5Min
+	case "load15":
+		return w.load15Min
+	}
+
+	return w.cpuPercents
+}
+
+// Like "1m 0.52", with a marker for how busy it is when those are on
+func (w *CPUWidget) getLegendText(series CPUChartSeries) string {
+	if !series.IsLoad {
+		return withSeverityMarker(fmt.Sprintf("%v %0.1f%%", series.Title, 100*w.cpuPercent),
+			percentToSeverity(int(100*w.cpuPercent), 0, 100, true))
+	}
+
+	load := w.mostRecent1MinLoad
+	if series.Name == "load5" {
+		load = w.mostRecent5MinLoad
+	} else if series.Name == "load15" {
+		load = w.mostRecent15MinLoad
+	}
 
+	loadPercent := int(scaleCPUChartValue(load, series, CPUChartScalePercent, w.numProcessors))
+
+	return withSeverityMarker(fmt.Sprintf("%v %0.2f", series.Title, load), percentToSeverity(loadPercent, 0, 100, true))
+}
+
+func (w *CPUWidget) refreshChart() {
+	// Adjust graph axes color by Load value (never bold), once there are processors to compare it to
+	if w.numProcessors > 0 {
 		loadPercent := float64(w.mostRecent5MinLoad) / float64(w.numProcessors)
+		w.widget.AxesColor = percentToAttribute(int(100.0*loadPercent), 0, 100, true)
+	}
+
+	chartRange := ChartRanges[w.chartRange]
+
+	w.widget.Data = map[string][]float64{}
+	w.widget.LineColor = map[string]ui.Attribute{}
+	w.widget.DataLabels = []string{}
+
+	legend := make([]string, 0, len(w.series)+1)
+
+	for _, name := range w.series {
+		series, _ := findCPUChartSeries(name)
+
+		// Two points per character, the average with the peaks behind it
+		data := buildChartData(w.getLiveSeries(series), series.Key, w.chartRange, w.widget.Width*2)
+
+		legend = append(legend, fmt.Sprintf("[━ %v](%s)", w.getLegendText(series), attributeToColorString(series.Color, "fg")))
+
+		if len(data.Values) == 0 {
+			continue
+		}
+
+		w.widget.Data[series.Name] = scaleCPUChartValues(data.Values, series, w.scale, w.numProcessors)
+		w.widget.LineColor[series.Name] = series.Color
+
+		// With more than one series the peaks would just be clutter
+		if data.Peaks != nil && len(w.series) == 1 {
+			w.widget.Data[series.Name+"-max"] = scaleCPUChartValues(data.Peaks, series, w.scale, w.numProcessors)
+			w.widget.LineColor[series.Name+"-max"] = series.Color &^ ui.AttrBold
+		}
+
+		if len(data.Labels) > len(w.widget.DataLabels) {
+			w.widget.DataLabels = data.Labels
+		}
+	}
 
-		cpuColorString := percentToAttributeString(int(100.0*w.cpuPercent), 0, 100, true)
+	// Where the load means every core is busy, which also keeps the scale from blowing up a little load
+	if w.numProcessors > 0 && len(w.widget.DataLabels) > 0 {
+		reference := make([]float64, len(w.widget.DataLabels))
 
-		loadColor := percentToAttribute(int(100.0*loadPercent), 0, 100, true)
-		loadColorString := percentToAttributeString(int(100.0*loadPercent), 0, 100, true)
+		for i := range reference {
+			reference[i] = cpuChartReference(w.scale, w.numProcessors)
+		}
 
-		w.widget.BorderLabel = fmt.Sprintf("[CPU: %0.2f%%](%s)[───](fg-white)[5m Load: %0.2f](%s)", w.cpuPercent*100, cpuColorString, w.mostRecent5MinLoad, loadColorString)
-		w.widget.Data["cpu"] = w.loadLast1Min
-		w.widget.DataLabels = w.timestamps
+		w.widget.Data["cores"] = reference
+		w.widget.LineColor["cores"] = ui.ColorWhite
 
-		// Adjust graph axes color by Load value (never bold)
-		w.widget.AxesColor = loadColor
+		legend = append(legend, fmt.Sprintf("[─ %d cores](fg-white)", w.numProcessors))
+	}
+
+	w.widget.BorderLabel = fmt.Sprintf("[CPU](fg-white)[───](fg-white)%v[───](fg-white)[%v, %v](fg-cyan)",
+		strings.Join(legend, " "), chartRange.Name, w.scale)
+}
+
+func (w *CPUWidget) handleEvent(e ui.Event) bool {
+	switch e.ID {
+	case ChartRangeKey:
+		w.chartRange = nextChartRange(w.chartRange)
+	case CPUChartSeriesKey:
+		w.series = nextCPUChartSeriesPreset(w.series)
+	case CPUChartScaleKey:
+		w.scale = w.scale.next()
+	default:
+		return false
+	}
+
+	w.refreshChart()
+
+	return true
+}
+
+func (w *CPUWidget) setCompact(compact bool) {
+	w.widget.Height = CPUWidgetHeight
+	w.times.Height = CPUTimeChartHeight
+
+	if compact {
+		w.widget.Height = CPUWidgetCompactHeight
+		w.times.Height = CPUTimeChartCompactHeight
 	}
 }
 
 func (w *CPUWidget) resize() {
-	// Update
+	// The charts fit themselves to the width, the cores fit as many to a row as they can
+	w.refreshChart()
+	w.refreshTimes()
+	w.refreshCores()
+}
+
+// Start the live chart with what was recorded before we started
+func (w *CPUWidget) loadHistory() {
+	since := time.Now().Add(-ChartRanges[0].Duration)
+
+	for _, series := range CPUChartSeriesList {
+		live := w.getLiveSeries(series)
+
+		for _, p := range queryHistory(series.Key, since) {
+			live.add(p.Timestamp, p.Value)
+		}
+	}
+
+	w.timeBreakdown.loadHistory(since)
 }
 
 func (w *CPUWidget) loadProcessorStats() {
+	now := time.Now()
+
 	// Read /proc/stat for the overall CPU percentage
 	stats, statErr := linuxproc.ReadStat("/proc/stat")
 
 	if statErr == nil {
-		// Save last two stats records
-		w.lastStat = w.curStat
-		w.curStat = stats.CPUStatAll
 		w.numProcessors = len(stats.CPUStats)
 
-		// Calculate usage percentage
-		// from: https://stackoverflow.com/a/23376195
-
-		prevIdle := w.lastStat.Idle + w.lastStat.IOWait
-		curIdle := w.curStat.Idle + w.curStat.IOWait
+		if percent, ok := w.cpuUsage.add(now, stats.CPUStatAll); ok {
+			w.cpuPercent = percent
+			w.cpuPercents.add(now, 100*percent)
+		}
 
-		prevNonIdle := w.lastStat.User + w.lastStat.Nice + w.lastStat.System + w.lastStat.IRQ + w.lastStat.SoftIRQ + w.lastStat.Steal
-		curNonIdle := w.curStat.User + w.curStat.Nice + w.curStat.System + w.curStat.IRQ + w.curStat.SoftIRQ + w.curStat.Steal
+		w.timeBreakdown.add(now, stats.CPUStatAll)
 
-		prevTotal := prevIdle + prevNonIdle
-		curTotal := curIdle + curNonIdle
+		// Start over if cores came or went
+		if len(w.coreUsage) != len(stats.CPUStats) {
+			w.coreUsage = make([]*CPUUsageTracker, len(stats.CPUStats))
+			w.corePercents = make([]float64, len(stats.CPUStats))
 
-		//  differentiate: actual value minus the previous one
-		totald := curTotal - prevTotal
-		idled := curIdle - prevIdle
+			for i := range w.coreUsage {
+				w.coreUsage[i] = NewCPUUsageTracker()
+			}
+		}
 
-		w.cpuPercent = (float64(totald - idled)) / float64(totald)
+		for i, stat := range stats.CPUStats {
+			if percent, ok := w.coreUsage[i].add(now, stat); ok {
+				w.corePercents[i] = percent
+			}
+		}
 	}
 
 	// Read load average
@@ -120,28 +457,29 @@ func (w *CPUWidget) loadProcessorStats() {
 	if loadErr == nil {
 		w.mostRecent1MinLoad = loadavg.Last1Min
 		w.mostRecent5MinLoad = loadavg.Last5Min
-		now := time.Now()
-		ts := fmt.Sprintf("%02d:%02d", now.Hour(), now.Minute())
-
-		// Record, keep a fixed number around
-		if len(w.loadLast1Min) > (w.widget.Width * 2) {
-			w.loadLast1Min = append(w.loadLast1Min[1:], loadavg.Last1Min)
-		} else {
-			w.loadLast1Min = append(w.loadLast1Min, loadavg.Last1Min)
-		}
+		w.mostRecent15MinLoad = loadavg.Last15Min
 
-		if len(w.loadLast5Min) > (w.widget.Width * 2) {
-			w.loadLast5Min = append(w.loadLast5Min[1:], loadavg.Last5Min)
-		} else {
-			w.loadLast5Min = append(w.loadLast5Min, loadavg.Last5Min)
-		}
+		w.load1Min.add(now, loadavg.Last1Min)
+		w.load5Min.add(now, loadavg.Last5Min)
+		w.load15Min.add(now, loadavg.Last15Min)
+	}
+}
 
-		if len(w.timestamps) > (w.widget.Width * 2) {
-			w.timestamps = append(w.timestamps[1:], ts)
-		} else {
-			w.timestamps = append(w.timestamps, ts)
-		}
+func (w *CPUWidget) getMetrics() []Metric {
+	metrics := []Metric{
+		newGaugeMetric("cpu", "usage_percent", "CPU utilization across all processors.", 100*w.cpuPercent),
+		newGaugeMetric("cpu", "processors", "Number of processors.", float64(w.numProcessors)),
+		newGaugeMetric("cpu", "load1", "1-minute load average.", w.mostRecent1MinLoad),
+		newGaugeMetric("cpu", "load5", "5-minute load average.", w.mostRecent5MinLoad),
+		newGaugeMetric("cpu", "load15", "15-minute load average.", w.mostRecent15MinLoad),
+	}
+
+	for core, percent := range w.corePercents {
+		metrics = append(metrics, newGaugeMetric("cpu", "core_usage_percent", "CPU utilization of one processor.",
+			100*percent, MetricLabel{Name: "core", Value: strconv.Itoa(core)}))
 	}
+
+	return append(metrics, w.timeBreakdown.getMetrics()...)
 }
 
 func (w *CPUWidget) getUpdateInterval() time.Duration {
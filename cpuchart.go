@@ -0,0 +1,153 @@
+package main
+
+/**
+ * What the CPU chart plots (CPU %, the 1/5/15 minute load averages) and on what scale, so load on a 4 core laptop and
+ * a 64 core build box reads the same.
+ */
+
+import (
+	"log"
+	"strings"
+
+	ui "github.com/gizak/termui"
+)
+
+////////////////////////////////////////////
+// Utility: CPU Chart Series
+////////////////////////////////////////////
+
+// Cycles through CPUChartSeriesPresets
+const CPUChartSeriesKey = "l"
+
+// Switches between CPUChartScales
+const CPUChartScaleKey = "n"
+
+type CPUChartSeries struct {
+	// For -cpu-series, and the chart's data
+	Name string
+	// In the legend
+	Title string
+	// In the history
+	Key   string
+	Color ui.Attribute
+	// Load averages count cores, CPU usage is a percent
+	IsLoad bool
+}
+
+var CPUChartSeriesList = []CPUChartSeries{
+	{Name: "cpu", Title: "cpu", Key: "cpu.usage_percent", Color: ui.ColorGreen | ui.AttrBold},
+	{Name: "load1", Title: "1m", Key: "cpu.load1", Color: ui.ColorBlue | ui.AttrBold, IsLoad: true},
+	{Name: "load5", Title: "5m", Key: "cpu.load5", Color: ui.ColorCyan, IsLoad: true},
+	{Name: "load15", Title: "15m", Key: "cpu.load15", Color: ui.ColorMagenta, IsLoad: true},
+}
+
+// What CPUChartSeriesKey goes through, in order
+var CPUChartSeriesPresets = [][]string{
+	{"cpu", "load1"},
+	{"load1", "load5", "load15"},
+	{"cpu", "load1", "load5", "load15"},
+	{"cpu"},
+	{"load1"},
+}
+
+func findCPUChartSeries(name string) (CPUChartSeries, bool) {
+	for _, series := range CPUChartSeriesList {
+		if series.Name == name {
+			return series, true
+		}
+	}
+
+	return CPUChartSeries{}, false
+}
+
+// Like "cpu,load1".  Names it doesn't know get logged and left out, and if that's all of them it's the first preset.
+func parseCPUChartSeries(names string) []string {
+	parsed := make([]string, 0)
+
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+
+		if _, ok := findCPUChartSeries(name); ok {
+			parsed = append(parsed, name)
+		} else if len(name) > 0 {
+			log.Printf("Unknown CPU chart series '%v'", name)
+		}
+	}
+
+	if len(parsed) == 0 {
+		return CPUChartSeriesPresets[0]
+	}
+
+	return parsed
+}
+
+// The preset after current, or the first one if current isn't a preset
+func nextCPUChartSeriesPreset(current []string) []string {
+	joined := strings.Join(current, ",")
+
+	for i, preset := range CPUChartSeriesPresets {
+		if strings.Join(preset, ",") == joined {
+			return CPUChartSeriesPresets[(i+1)%len(CPUChartSeriesPresets)]
+		}
+	}
+
+	return CPUChartSeriesPresets[0]
+}
+
+////////////////////////////////////////////
+// Utility: CPU Chart Scale
+////////////////////////////////////////////
+
+type CPUChartScale int
+
+const (
+	// Everything as a percent of all the cores: 100 is fully busy however many there are
+	CPUChartScalePercent CPUChartScale = iota
+	// Everything as a number of cores, like the load average itself
+	CPUChartScaleCores
+)
+
+var CPUChartScaleNames = []string{"% of cores", "cores"}
+
+func (s CPUChartScale) String() string {
+	return CPUChartScaleNames[s]
+}
+
+func (s CPUChartScale) next() CPUChartScale {
+	return (s + 1) % CPUChartScale(len(CPUChartScaleNames))
+}
+
+// A value from the series in the scale's units
+func scaleCPUChartValue(value float64, series CPUChartSeries, scale CPUChartScale, cores int) float64 {
+	if cores <= 0 {
+		return value
+	}
+
+	switch {
+	case series.IsLoad && scale == CPUChartScalePercent:
+		return 100 * value / float64(cores)
+	case !series.IsLoad && scale == CPUChartScaleCores:
+		return value * float64(cores) / 100
+	}
+
+	return value
+}
+
+func scaleCPUChartValues(values []float64, series CPUChartSeries, scale CPUChartScale, cores int) []float64 {
+	scaled := make([]float64, len(values))
+
+	for i, v := range values {
+		scaled[i] = scaleCPUChartValue(v, series, scale, cores)
+	}
+
+	return scaled
+}
+
+// Where every core is busy, the reference line
+func cpuChartReference(scale CPUChartScale, cores int) float64 {
+	if scale == CPUChartScaleCores {
+		return float64(cores)
+	}
+
+	return 100
+}
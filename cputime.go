@@ -0,0 +1,207 @@
+package main
+
+/**
+ * Where the CPU time goes (user, system, iowait, irq, steal), since one busy number can't tell a disk thrashing or a
+ * noisy VM neighbor from real work.
+ */
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	linuxproc "github.com/c9s/goprocinfo/linux"
+)
+
+////////////////////////////////////////////
+// Utility: CPU Time Categories
+////////////////////////////////////////////
+
+type CPUTimeCategory struct {
+	// The mode label on the metrics
+	Name string
+	// A different one for each, so it reads without color
+	Character rune
+	Color     string
+	jiffies   func(stat linuxproc.CPUStat) uint64
+}
+
+// Bottom of the stack first
+var CPUTimeCategories = []CPUTimeCategory{
+	// Niced is still user work
+	{Name: "user", Character: '█', Color: "fg-green", jiffies: func(s linuxproc.CPUStat) uint64 { return s.User + s.Nice }},
+	{Name: "system", Character: '▓', Color: "fg-blue", jiffies: func(s linuxproc.CPUStat) uint64 { return s.System }},
+	{Name: "irq", Character: '▒', Color: "fg-magenta", jiffies: func(s linuxproc.CPUStat) uint64 { return s.IRQ + s.SoftIRQ }},
+	{Name: "iowait", Character: '░', Color: "fg-yellow", jiffies: func(s linuxproc.CPUStat) uint64 { return s.IOWait }},
+	{Name: "steal", Character: '▚', Color: "fg-red", jiffies: func(s linuxproc.CPUStat) uint64 { return s.Steal }},
+}
+
+func cpuStatTotalJiffies(s linuxproc.CPUStat) uint64 {
+	return s.User + s.Nice + s.System + s.Idle + s.IOWait + s.IRQ + s.SoftIRQ + s.Steal
+}
+
+////////////////////////////////////////////
+// Utility: CPU Time Breakdown
+////////////////////////////////////////////
+
+// The share of the time each category got, every reading
+type CPUTimeBreakdown struct {
+	previous    linuxproc.CPUStat
+	hasPrevious bool
+	// From 0 to 1, one per category
+	history []*TimeSeries
+}
+
+func NewCPUTimeBreakdown(capacity int) *CPUTimeBreakdown {
+	b := &CPUTimeBreakdown{history: make([]*TimeSeries, len(CPUTimeCategories))}
+
+	for i := range b.history {
+		b.history[i] = NewTimeSeries(capacity)
+	}
+
+	return b
+}
+
+// Since the last reading, or since boot the first time
+func (b *CPUTimeBreakdown) add(now time.Time, stat linuxproc.CPUStat) {
+	previous := linuxproc.CPUStat{}
+	if b.hasPrevious {
+		previous = b.previous
+	}
+
+	b.previous = stat
+	b.hasPrevious = true
+
+	total := float64(cpuStatTotalJiffies(stat)) - float64(cpuStatTotalJiffies(previous))
+	if total <= 0 {
+		return
+	}
+
+	for i, category := range CPUTimeCategories {
+		b.history[i].add(now, (float64(category.jiffies(stat))-float64(category.jiffies(previous)))/total)
+	}
+}
+
+// Start with what was recorded before we started
+func (b *CPUTimeBreakdown) loadHistory(since time.Time) {
+	for i, category := range CPUTimeCategories {
+		for _, p := range queryHistory(cpuTimeMetricKey(category), since) {
+			b.history[i].add(p.Timestamp, p.Value/100)
+		}
+	}
+}
+
+// From 0 to 1, for each category
+func (b *CPUTimeBreakdown) latest() []float64 {
+	shares := make([]float64, len(b.history))
+
+	for i, series := range b.history {
+		if p, ok := series.last(); ok {
+			shares[i] = p.Value
+		}
+	}
+
+	return shares
+}
+
+// The last n readings (or fewer), oldest first, each with a share for every category
+func (b *CPUTimeBreakdown) lastN(n int) [][]float64 {
+	if n < 0 {
+		n = 0
+	}
+
+	for _, series := range b.history {
+		if series.len() < n {
+			n = series.len()
+		}
+	}
+
+	samples := make([][]float64, n)
+
+	for i := range samples {
+		samples[i] = make([]float64, len(b.history))
+	}
+
+	for c, series := range b.history {
+		for i, p := range series.lastN(n) {
+			samples[i][c] = p.Value
+		}
+	}
+
+	return samples
+}
+
+func (b *CPUTimeBreakdown) getMetrics() []Metric {
+	metrics := make([]Metric, 0, len(CPUTimeCategories))
+
+	for i, share := range b.latest() {
+		metrics = append(metrics, newGaugeMetric("cpu", "time_percent", "Share of CPU time spent in each mode.",
+			100*share, MetricLabel{Name: "mode", Value: CPUTimeCategories[i].Name}))
+	}
+
+	return metrics
+}
+
+// Like "cpu.iowait.time_percent", how the history knows it
+func cpuTimeMetricKey(category CPUTimeCategory) string {
+	return "cpu." + category.Name + ".time_percent"
+}
+
+////////////////////////////////////////////
+// Utility: Stacked Chart
+////////////////////////////////////////////
+
+// A column per reading, newest on the right, with the categories stacked up from the bottom (0 to 100%).  Each cell
+// is whichever category covers its middle.
+func buildStackedChartLines(samples [][]float64, width int, height int) []string {
+	if width <= 0 || height <= 0 {
+		return []string{}
+	}
+
+	if len(samples) > width {
+		samples = samples[len(samples)-width:]
+	}
+
+	lines := make([]string, height)
+
+	for row := range lines {
+		level := (float64(height-row) - 0.5) / float64(height)
+		runes := make([]styledRune, width-len(samples), width)
+
+		for i := range runes {
+			runes[i] = styledRune{' ', ""}
+		}
+
+		for _, shares := range samples {
+			cell := styledRune{' ', ""}
+			stacked := 0.0
+
+			for c, share := range shares {
+				stacked += share
+
+				if stacked >= level {
+					cell = styledRune{CPUTimeCategories[c].Character, CPUTimeCategories[c].Color}
+					break
+				}
+			}
+
+			runes = append(runes, cell)
+		}
+
+		lines[row] = fromStyledRunes(runes)
+	}
+
+	return lines
+}
+
+// Like "█ user 12%  ▓ system 3%", in the categories' colors
+func buildCPUTimeLegend(shares []float64) string {
+	entries := make([]string, len(shares))
+
+	for i, share := range shares {
+		category := CPUTimeCategories[i]
+		entries[i] = fmt.Sprintf("[%c %v %0.0f%%](%s)", category.Character, category.Name, 100*share, category.Color)
+	}
+
+	return strings.Join(entries, " ")
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	linuxproc "github.com/c9s/goprocinfo/linux"
+)
+
+func TestCPUTimeBreakdownLastN(t *testing.T) {
+	b := NewCPUTimeBreakdown(4)
+
+	b.add(timeSeriesTestStart, linuxproc.CPUStat{User: 100, Idle: 100})
+	b.add(timeSeriesTestStart.Add(time.Second), linuxproc.CPUStat{User: 150, System: 25, Idle: 125})
+
+	if got := b.lastN(-1); len(got) != 0 {
+		t.Errorf("lastN(-1) is %v", got)
+	}
+
+	got := b.lastN(10)
+	if len(got) != 2 {
+		t.Fatalf("lastN(10) is %v", got)
+	}
+
+	if got[1][0] != 0.5 || got[1][1] != 0.25 {
+		t.Errorf("latest shares are %v", got[1])
+	}
+}
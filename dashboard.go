@@ -0,0 +1,366 @@
+package main
+
+/**
+ * The set of widgets on the dashboard and how they're laid out.
+ */
+
+import (
+	ui "github.com/gizak/termui"
+)
+
+////////////////////////////////////////////
+// Utility: Layout
+////////////////////////////////////////////
+
+type LayoutColumn struct {
+	Span    int      `json:"span"`
+	Widgets []string `json:"widgets"`
+}
+
+type LayoutRow []LayoutColumn
+
+// By widget name.  A widget that's already a column (like the disks) has to be alone in its column.
+var DashboardLayout = []LayoutRow{
+	{{Span: 6, Widgets: []string{"hostinfo", "battery", "audio", "weather"}}, {Span: 6, Widgets: []string{"cpu"}}},
+	{{Span: 6, Widgets: []string{"disk"}}, {Span: 6, Widgets: []string{"network", "processes"}}},
+	{{Span: 12, Widgets: []string{"repos"}}},
+	{{Span: 4, Widgets: []string{"twitter1"}}, {Span: 4, Widgets: []string{"twitter2"}}, {Span: 4, Widgets: []string{"twitter3"}}},
+}
+
+// Sizes from the header comment in main.go.  Each applies when the terminal is narrower than Width or shorter than
+// Height (zero means that side doesn't matter), and all the ones that apply are combined.
+type LayoutBreakpoint struct {
+	Width  int
+	Height int
+	// Every column gets a row to itself
+	Stack bool
+	// Widgets that have a smaller version switch to it
+	Compact bool
+	// Left out of the layout (they still collect)
+	Hidden []string
+}
+
+var LayoutBreakpoints = []LayoutBreakpoint{
+	// Like 104x56 and 100x50
+	{Width: 150, Height: 60, Compact: true, Hidden: []string{"twitter1", "twitter2", "twitter3"}},
+	// Like 80x40
+	{Width: 100, Stack: true},
+	{Width: 100, Height: 45, Hidden: []string{"weather"}},
+}
+
+// What the breakpoints for one terminal size add up to
+type LayoutMode struct {
+	Stack   bool
+	Compact bool
+	Hidden  map[string]bool
+}
+
+func getLayoutMode(width int, height int) LayoutMode {
+	mode := LayoutMode{Hidden: map[string]bool{}}
+
+	for _, bp := range LayoutBreakpoints {
+		if (bp.Width > 0 && width < bp.Width) || (bp.Height > 0 && height < bp.Height) {
+			mode.Stack = mode.Stack || bp.Stack
+			mode.Compact = mode.Compact || bp.Compact
+
+			for _, name := range bp.Hidden {
+				mode.Hidden[name] = true
+			}
+		}
+	}
+
+	return mode
+}
+
+func (m LayoutMode) equals(other LayoutMode) bool {
+	if m.Stack != other.Stack || m.Compact != other.Compact || len(m.Hidden) != len(other.Hidden) {
+		return false
+	}
+
+	for name := range m.Hidden {
+		if !other.Hidden[name] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Drops the hidden widgets (and any rows or columns left empty), then stacks the columns or widens what's left to fill
+// the row
+func applyLayoutMode(layout []LayoutRow, mode LayoutMode) []LayoutRow {
+	rows := make([]LayoutRow, 0, len(layout))
+
+	for _, layoutRow := range layout {
+		row := make(LayoutRow, 0, len(layoutRow))
+		span := 0
+
+		for _, layoutCol := range layoutRow {
+			widgets := make([]string, 0, len(layoutCol.Widgets))
+
+			for _, name := range layoutCol.Widgets {
+				if !mode.Hidden[name] {
+					widgets = append(widgets, name)
+				}
+			}
+
+			if len(widgets) > 0 {
+				row = append(row, LayoutColumn{Span: layoutCol.Span, Widgets: widgets})
+				span += layoutCol.Span
+			}
+		}
+
+		for i := range row {
+			if mode.Stack {
+				rows = append(rows, LayoutRow{{Span: 12, Widgets: row[i].Widgets}})
+			} else {
+				row[i].Span = row[i].Span * 12 / span
+			}
+		}
+
+		if !mode.Stack && len(row) > 0 {
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
+
+func buildLayoutRows(layout []LayoutRow, lookup func(name string) ui.GridBufferer) []*ui.Row {
+	rows := make([]*ui.Row, 0, len(layout))
+
+	for _, layoutRow := range layout {
+		cols := make([]*ui.Row, 0, len(layoutRow))
+
+		for _, layoutCol := range layoutRow {
+			if len(layoutCol.Widgets) == 1 {
+				if column, isColumn := lookup(layoutCol.Widgets[0]).(*ui.Row); isColumn {
+					column.Span = layoutCol.Span
+					cols = append(cols, column)
+					continue
+				}
+			}
+
+			gridWidgets := make([]ui.GridBufferer, 0, len(layoutCol.Widgets))
+
+			for _, name := range layoutCol.Widgets {
+				if w := lookup(name); w != nil {
+					gridWidgets = append(gridWidgets, w)
+				}
+			}
+
+			cols = append(cols, ui.NewCol(layoutCol.Span, 0, gridWidgets...))
+		}
+
+		rows = append(rows, ui.NewRow(cols...))
+	}
+
+	return rows
+}
+
+// In reading order, left to right then top to bottom
+func layoutWidgetNames(layout []LayoutRow) []string {
+	names := make([]string, 0)
+
+	for _, layoutRow := range layout {
+		for _, layoutCol := range layoutRow {
+			names = append(names, layoutCol.Widgets...)
+		}
+	}
+
+	return names
+}
+
+////////////////////////////////////////////
+// Utility: Dashboard
+////////////////////////////////////////////
+
+type NamedWidget struct {
+	Name   string
+	Widget CAHWidget
+}
+
+// Something the rendering loop can show: the local dashboard, or one attached to an agent
+type DashboardView interface {
+	getHeader() *HeaderWidget
+	getWidgets() []CAHWidget
+	// Fires when there's something new to show without waiting for the next tick
+	getChanges() <-chan bool
+	// Called after all the widgets have updated
+	afterUpdate()
+	// Picks the layout for a terminal this big, returns true if it changed
+	setTerminalSize(width int, height int) bool
+	buildRows() []*ui.Row
+	// The widgets in the layout, for focusing and zooming
+	getFocusNames() []string
+	getGridWidget(name string) ui.GridBufferer
+	// What got clicked on, in more detail, if there's any to give
+	getDetailAt(x int, y int) (title string, lines []string, ok bool)
+	// Gets the events the loop doesn't handle itself, returns true if anything needs redrawing
+	handleEvent(e ui.Event) bool
+	// The same, for the focused widget, which gets them first
+	handleFocusedEvent(name string, e ui.Event) bool
+}
+
+type Dashboard struct {
+	userHostHeader string
+	header         *HeaderWidget
+	widgets        []NamedWidget
+	// DashboardLayout, plus the configured charts
+	layout []LayoutRow
+	// What fits the terminal
+	mode        LayoutMode
+	shownLayout []LayoutRow
+}
+
+// For the terminal
+func NewDashboard() *Dashboard {
+	header := NewHeaderWidget()
+
+	return newDashboard(header.userHostHeader, header)
+}
+
+// For running without a terminal (there's no header to draw)
+func NewHeadlessDashboard() *Dashboard {
+	return newDashboard(getUserHostHeader(), nil)
+}
+
+func newDashboard(userHostHeader string, header *HeaderWidget) *Dashboard {
+	d := &Dashboard{
+		userHostHeader: userHostHeader,
+		header:         header,
+		widgets: []NamedWidget{
+			{"hostinfo", NewHostInfoWidget()},
+			{"network", NewNetworkWidget()},
+			{"battery", NewBatteryWidget()},
+			{"audio", NewAudioWidget()},
+			{"disk", NewDiskColumn(6, 0)},
+			{"cpu", NewCPUWidget()},
+			{"processes", NewProcessWidget()},
+			{"repos", NewGitRepoWidget()},
+			{"twitter1", NewTwitterWidget(GetTwitterAccount1(), ui.ColorBlue|ui.AttrBold)},
+			{"twitter2", NewTwitterWidget(GetTwitterAccount2(), ui.ColorCyan)},
+			{"twitter3", NewTwitterWidget(GetTwitterAccount3(), ui.ColorMagenta)},
+			{"weather", NewWeatherWidget(GetWeatherLocation())},
+		},
+	}
+
+	definitions := getChartDefinitions()
+
+	for _, definition := range definitions {
+		d.widgets = append(d.widgets, NamedWidget{definition.widgetName(), NewMetricChartWidget(definition)})
+	}
+
+	d.layout = append(append([]LayoutRow{}, DashboardLayout...), buildChartLayout(definitions)...)
+	// Everything, until there's a terminal size
+	d.mode = LayoutMode{Hidden: map[string]bool{}}
+	d.shownLayout = d.layout
+
+	if header != nil {
+		d.widgets = append([]NamedWidget{{"header", header}}, d.widgets...)
+	}
+
+	return d
+}
+
+func (d *Dashboard) getHeader() *HeaderWidget {
+	return d.header
+}
+
+func (d *Dashboard) getWidgets() []CAHWidget {
+	widgets := make([]CAHWidget, len(d.widgets))
+
+	for i, w := range d.widgets {
+		widgets[i] = w.Widget
+	}
+
+	return widgets
+}
+
+func (d *Dashboard) getChanges() <-chan bool {
+	// Only ever changes on the tick
+	return nil
+}
+
+func (d *Dashboard) afterUpdate() {
+	latestMetrics.record(d.getWidgets())
+	latestDashboardState.record(d)
+}
+
+// Every widget that wants it gets the event
+func (d *Dashboard) handleEvent(e ui.Event) bool {
+	handled := false
+
+	for _, w := range d.getWidgets() {
+		if handler, ok := w.(EventHandler); ok && handler.handleEvent(e) {
+			handled = true
+		}
+	}
+
+	return handled
+}
+
+func (d *Dashboard) handleFocusedEvent(name string, e ui.Event) bool {
+	if handler, ok := d.getWidget(name).(FocusedEventHandler); ok {
+		return handler.handleFocusedEvent(e)
+	}
+
+	return false
+}
+
+func (d *Dashboard) setTerminalSize(width int, height int) bool {
+	mode := getLayoutMode(width, height)
+	if mode.equals(d.mode) {
+		return false
+	}
+
+	d.mode = mode
+	d.shownLayout = applyLayoutMode(d.layout, mode)
+
+	for _, w := range d.getWidgets() {
+		if compactWidget, ok := w.(CompactWidget); ok {
+			compactWidget.setCompact(mode.Compact)
+		}
+	}
+
+	return true
+}
+
+func (d *Dashboard) getWidget(name string) CAHWidget {
+	for _, w := range d.widgets {
+		if w.Name == name {
+			return w.Widget
+		}
+	}
+
+	return nil
+}
+
+func (d *Dashboard) getGridWidget(name string) ui.GridBufferer {
+	if w := d.getWidget(name); w != nil {
+		return w.getGridWidget()
+	}
+
+	return nil
+}
+
+func (d *Dashboard) buildRows() []*ui.Row {
+	return buildLayoutRows(d.shownLayout, d.getGridWidget)
+}
+
+func (d *Dashboard) getFocusNames() []string {
+	return getLayoutFocusNames(d.shownLayout, d.getGridWidget)
+}
+
+// Only widgets that are showing get asked
+func (d *Dashboard) getDetailAt(x int, y int) (string, []string, bool) {
+	for _, name := range d.getFocusNames() {
+		if provider, ok := d.getWidget(name).(DetailProvider); ok {
+			if title, lines, found := provider.getDetailAt(x, y); found {
+				return title, lines, true
+			}
+		}
+	}
+
+	return "", nil, false
+}
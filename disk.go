@@ -25,6 +25,7 @@ type DiskUsage struct {
 	FSType               string
 	TotalSizeInBytes     uint64
 	AvailableSizeInBytes uint64
+	FreeSizeInBytes      uint64
 	FreePercentage       float64
 	InodesInUse          uint64
 	TotalInodes          uint64
@@ -73,6 +74,7 @@ func loadDiskUsage() map[string]DiskUsage {
 			} else {
 				var totalBytes uint64 = 0
 				var availBytes uint64 = 0
+				var freeBytes uint64 = 0
 				var bytesFreePercent float64 = 0
 				var totalInodes uint64 = 0
 				var freeInodes uint64 = 0
@@ -88,6 +90,7 @@ func loadDiskUsage() map[string]DiskUsage {
 
 				totalBytes = statfs.Blocks * blocksize
 				availBytes = statfs.Bavail * blocksize
+				freeBytes = statfs.Bfree * blocksize
 				if totalBytes > 0 {
 					bytesFreePercent = float64(availBytes) / float64(totalBytes)
 				} else {
@@ -108,6 +111,7 @@ func loadDiskUsage() map[string]DiskUsage {
 					FSType:               mnt.FSType,
 					TotalSizeInBytes:     totalBytes,
 					AvailableSizeInBytes: availBytes,
+					FreeSizeInBytes:      freeBytes,
 					FreePercentage:       bytesFreePercent,
 					TotalInodes:          totalInodes,
 					InodesInUse:          totalInodes - freeInodes,
@@ -164,10 +168,19 @@ var cachedDiskUsage = NewCachedDiskUsage()
 
 const DiskHeaderText = "--- Disks ---"
 
+// How many disks fit on a small terminal, the fullest ones get shown
+const DiskCompactCount = 3
+
+// Past this many the column scrolls, with the mouse wheel
+const DiskMaxGauges = 6
+
 type DiskColumn struct {
 	column  *ui.Row
 	header  *ui.Paragraph
 	widgets []*ui.Gauge
+	compact bool
+	// Into widgets, the column only gets the ones scrolled to
+	position ScrollPosition
 }
 
 func NewDiskColumn(span int, offset int) *DiskColumn {
@@ -198,39 +211,145 @@ func (w *DiskColumn) getColumn() *ui.Row {
 }
 
 func (w *DiskColumn) update() {
-	w.header.Text = centerString(w.header.Width, DiskHeaderText)
-	//w.header.Text = DiskHeaderText
+	cachedDiskUsage.update()
 
-	gauges := make([]*ui.Gauge, 0)
+	usages := make([]DiskUsage, 0, len(cachedDiskUsage.LastUsage))
 
 	for _, d := range cachedDiskUsage.LastUsage {
+		usages = append(usages, d)
+	}
+
+	if w.compact && len(usages) > DiskCompactCount {
+		sort.Slice(usages, func(i, j int) bool { return usages[i].FreePercentage < usages[j].FreePercentage })
+		usages = usages[:DiskCompactCount]
+	}
+
+	gauges := make([]*ui.Gauge, 0)
+
+	for _, d := range usages {
 		gauges = append(gauges, NewDiskGauge(d))
 	}
 
 	sort.Sort(ByMountPoint(gauges))
 
+	w.widgets = gauges
+	w.refreshGauges()
+}
+
+func (w *DiskColumn) refreshGauges() {
+	shown := len(w.widgets)
+	if shown > DiskMaxGauges {
+		shown = DiskMaxGauges
+	}
+
+	w.position.setSize(shown, len(w.widgets))
+
 	w.column.Cols = []*ui.Row{}
 	ir := w.column
 
-	for _, widget := range gauges {
+	// The header only shows up to say there's more
+	if indicator := w.position.indicator(); len(indicator) > 0 {
+		w.header.Text = centerString(w.header.Width, fmt.Sprintf("--- Disks ── %v ---", indicator))
+
+		nr := &ui.Row{Span: 12, Widget: w.header}
+		ir.Cols = []*ui.Row{nr}
+		ir = nr
+	}
+
+	for _, widget := range w.widgets[w.position.offset : w.position.offset+shown] {
 		nr := &ui.Row{Span: 12, Widget: widget}
 		ir.Cols = []*ui.Row{nr}
 		ir = nr
 	}
 }
 
+func (w *DiskColumn) handleEvent(e ui.Event) bool {
+	if e.ID != "<MouseWheelUp>" && e.ID != "<MouseWheelDown>" {
+		return false
+	}
+
+	x, y, _ := getMousePosition(e)
+	if !gridWidgetContains(w.column, x, y) || !w.position.handleScrollEvent(e, w.position.shown) {
+		return false
+	}
+
+	w.refreshGauges()
+
+	return true
+}
+
+// Everything statfs said about the disk under the pointer
+func (w *DiskColumn) getDetailAt(x int, y int) (string, []string, bool) {
+	for _, g := range w.widgets[w.position.offset : w.position.offset+w.position.shown] {
+		if !gridWidgetContains(g, x, y) {
+			continue
+		}
+
+		d, ok := cachedDiskUsage.LastUsage[g.BorderLabel]
+		if !ok {
+			return "", nil, false
+		}
+
+		lines := []string{
+			fmt.Sprintf("Mount point: %v", d.MountPoint),
+			fmt.Sprintf("Filesystem:  %v", d.FSType),
+			fmt.Sprintf("Size:        %v", prettyPrintBytes(d.TotalSizeInBytes)),
+			fmt.Sprintf("Used:        %v", prettyPrintBytes(d.TotalSizeInBytes-d.FreeSizeInBytes)),
+			fmt.Sprintf("Available:   %v (%0.1f%%)", prettyPrintBytes(d.AvailableSizeInBytes), 100*d.FreePercentage),
+			fmt.Sprintf("Free:        %v (including reserved)", prettyPrintBytes(d.FreeSizeInBytes)),
+			fmt.Sprintf("Inodes:      %d of %d used (%0.1f%% free)", d.InodesInUse, d.TotalInodes, 100*d.FreeInodesPercentage),
+		}
+
+		return "Disk: " + d.MountPoint, lines, true
+	}
+
+	return "", nil, false
+}
+
+func (w *DiskColumn) setCompact(compact bool) {
+	w.compact = compact
+	w.update()
+}
+
 func (w *DiskColumn) resize() {
 	// Do nothing
 }
 
+func (w *DiskColumn) getMetrics() []Metric {
+	mountPoints := make([]string, 0, len(cachedDiskUsage.LastUsage))
+
+	for mountPoint := range cachedDiskUsage.LastUsage {
+		mountPoints = append(mountPoints, mountPoint)
+	}
+
+	sort.Strings(mountPoints)
+
+	metrics := make([]Metric, 0)
+
+	for _, mountPoint := range mountPoints {
+		d := cachedDiskUsage.LastUsage[mountPoint]
+		labels := []MetricLabel{{Name: "mountpoint", Value: d.MountPoint}, {Name: "fstype", Value: d.FSType}}
+
+		metrics = append(metrics,
+			newGaugeMetric("disk", "size_bytes", "Total size of the filesystem.", float64(d.TotalSizeInBytes), labels...),
+			newGaugeMetric("disk", "available_bytes", "Bytes available to unprivileged users.", float64(d.AvailableSizeInBytes), labels...),
+			newGaugeMetric("disk", "free_bytes", "Bytes free, including those reserved for root.", float64(d.FreeSizeInBytes), labels...),
+			newGaugeMetric("disk", "inodes", "Total inodes on the filesystem.", float64(d.TotalInodes), labels...),
+			newGaugeMetric("disk", "inodes_used", "Inodes in use on the filesystem.", float64(d.InodesInUse), labels...))
+	}
+
+	return metrics
+}
+
 func NewDiskGauge(usage DiskUsage) *ui.Gauge {
 	free := int(100 * usage.FreePercentage)
 	g := ui.NewGauge()
 	g.BorderLabel = usage.MountPoint
 	g.Height = 3
 	g.Percent = free
-	g.Label = fmt.Sprintf("Free: %s/%s (%d%%)",
-		prettyPrintBytes(usage.AvailableSizeInBytes), prettyPrintBytes(usage.TotalSizeInBytes), free)
+	g.Label = withSeverityMarker(fmt.Sprintf("Free: %s/%s (%d%%)",
+		prettyPrintBytes(usage.AvailableSizeInBytes), prettyPrintBytes(usage.TotalSizeInBytes), free),
+		percentToSeverity(free, 0, 100, false))
 	g.PercentColor = ui.ColorWhite | ui.AttrBold
 
 	g.BarColor = percentToAttribute(free, 0, 100, false)
@@ -0,0 +1,272 @@
+package main
+
+/**
+ * Focusing and zooming widgets, and popping up the details of whatever got clicked.
+ */
+
+import (
+	"strings"
+	"time"
+
+	ui "github.com/gizak/termui"
+)
+
+////////////////////////////////////////////
+// Utility: Widget Focus
+////////////////////////////////////////////
+
+const FocusBorderColor = ui.ColorYellow | ui.AttrBold
+
+// Two clicks on the same widget closer together than this zoom it
+const DoubleClickInterval = 400 * time.Millisecond
+
+// Anything drawn from a layout of named widgets
+type FocusableDashboard interface {
+	// The widgets that are showing, in reading order
+	getFocusNames() []string
+	getGridWidget(name string) ui.GridBufferer
+	buildRows() []*ui.Row
+}
+
+// Names from the layout that have a widget to go with them
+func getLayoutFocusNames(layout []LayoutRow, lookup func(name string) ui.GridBufferer) []string {
+	names := make([]string, 0)
+
+	for _, name := range layoutWidgetNames(layout) {
+		if lookup(name) != nil {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+type WidgetFocus struct {
+	// Index into the focus names, -1 for nothing
+	focus  int
+	zoomed bool
+
+	// Put back what focusing and zooming changed
+	restoreBorder func()
+	restoreHeight func()
+
+	lastClick      time.Time
+	lastClickFocus int
+}
+
+func NewWidgetFocus() *WidgetFocus {
+	return &WidgetFocus{focus: -1, lastClickFocus: -1}
+}
+
+func (f *WidgetFocus) next(count int) {
+	if count > 0 {
+		f.focus = (f.focus + 1) % count
+	}
+}
+
+func (f *WidgetFocus) previous(count int) {
+	if count > 0 {
+		f.focus = (f.focus - 1 + count) % count
+	}
+}
+
+func (f *WidgetFocus) toggleZoom() {
+	f.zoomed = !f.zoomed && f.focus >= 0
+}
+
+// Returns true if it was zoomed
+func (f *WidgetFocus) unzoom() bool {
+	zoomed := f.zoomed
+	f.zoomed = false
+
+	return zoomed
+}
+
+// The name of the focused widget, false for nothing
+func (f *WidgetFocus) getFocusName(d FocusableDashboard) (string, bool) {
+	names := d.getFocusNames()
+
+	if f.focus < 0 || f.focus >= len(names) {
+		return "", false
+	}
+
+	return names[f.focus], true
+}
+
+// Whether what's under the pointer already has the focus
+func (f *WidgetFocus) isFocusedAt(d FocusableDashboard, x int, y int) bool {
+	names := d.getFocusNames()
+
+	return f.focus >= 0 && f.focus < len(names) && gridWidgetContains(d.getGridWidget(names[f.focus]), x, y)
+}
+
+// Focuses whatever's under the pointer, and zooms (or unzooms) it on a double click.  Returns true if it was a double
+// click.
+func (f *WidgetFocus) click(d FocusableDashboard, x int, y int) bool {
+	clicked := -1
+
+	for i, name := range d.getFocusNames() {
+		if gridWidgetContains(d.getGridWidget(name), x, y) {
+			clicked = i
+		}
+	}
+
+	now := time.Now()
+	double := clicked >= 0 && clicked == f.lastClickFocus && now.Sub(f.lastClick) < DoubleClickInterval
+
+	f.focus = clicked
+	f.lastClick = now
+	f.lastClickFocus = clicked
+
+	if double {
+		f.toggleZoom()
+
+		// A third click starts over
+		f.lastClickFocus = -1
+	} else if clicked < 0 {
+		f.zoomed = false
+	}
+
+	return double
+}
+
+func (f *WidgetFocus) restore() {
+	if f.restoreBorder != nil {
+		f.restoreBorder()
+		f.restoreBorder = nil
+	}
+
+	if f.restoreHeight != nil {
+		f.restoreHeight()
+		f.restoreHeight = nil
+	}
+}
+
+// Everything, or just the zoomed widget stretched to height, with the focused one's border colored
+func (f *WidgetFocus) buildRows(d FocusableDashboard, height int) []*ui.Row {
+	f.restore()
+
+	names := d.getFocusNames()
+	if f.focus >= len(names) {
+		f.focus = -1
+		f.zoomed = false
+	}
+
+	if f.focus < 0 {
+		return d.buildRows()
+	}
+
+	name := names[f.focus]
+	gridWidget := d.getGridWidget(name)
+
+	f.restoreBorder = setBorderColor(gridWidget, FocusBorderColor)
+
+	if !f.zoomed {
+		return d.buildRows()
+	}
+
+	if b := getBlock(gridWidget); b != nil {
+		previousHeight := b.Height
+		b.Height = height
+		f.restoreHeight = func() { b.Height = previousHeight }
+	}
+
+	return buildLayoutRows([]LayoutRow{{{Span: 12, Widgets: []string{name}}}}, d.getGridWidget)
+}
+
+// The box around a widget, nil for columns
+func getBlock(gridWidget ui.GridBufferer) *ui.Block {
+	switch w := gridWidget.(type) {
+	case *ui.Paragraph:
+		return &w.Block
+	case *ui.List:
+		return &w.Block
+	case *ui.Gauge:
+		return &w.Block
+	case *ui.Table:
+		return &w.Block
+	case *ui.LineChart:
+		return &w.Block
+	case *ui.Sparklines:
+		return &w.Block
+	}
+
+	return nil
+}
+
+// Columns (like the disks) color all of their children.  Returns what puts the old colors back.
+func setBorderColor(gridWidget ui.GridBufferer, color ui.Attribute) func() {
+	restores := make([]func(), 0)
+
+	if b := getBlock(gridWidget); b != nil {
+		previousColor := b.BorderFg
+		b.BorderFg = color
+		restores = append(restores, func() { b.BorderFg = previousColor })
+	}
+
+	if r, isColumn := gridWidget.(*ui.Row); isColumn {
+		if r.Widget != nil {
+			restores = append(restores, setBorderColor(r.Widget, color))
+		}
+
+		for _, col := range r.Cols {
+			restores = append(restores, setBorderColor(col, color))
+		}
+	}
+
+	return func() {
+		for _, restore := range restores {
+			restore()
+		}
+	}
+}
+
+// Where the grid last put it
+func gridWidgetContains(gridWidget ui.GridBufferer, x int, y int) bool {
+	if b := getBlock(gridWidget); b != nil {
+		return x >= b.X && x < b.X+b.Width && y >= b.Y && y < b.Y+b.Height
+	}
+
+	if r, isColumn := gridWidget.(*ui.Row); isColumn {
+		return x >= r.X && x < r.X+r.Width && y >= r.Y && y < r.Y+r.GetHeight()
+	}
+
+	return false
+}
+
+func getMousePosition(e ui.Event) (int, int, bool) {
+	mouse, ok := e.Payload.(ui.Mouse)
+
+	return mouse.X, mouse.Y, ok
+}
+
+////////////////////////////////////////////
+// Utility: Details
+////////////////////////////////////////////
+
+// Widgets with more to say about the thing at a spot on the screen (like one repo out of the table)
+type DetailProvider interface {
+	getDetailAt(x int, y int) (title string, lines []string, ok bool)
+}
+
+// Drawn over the middle of the dashboard until it's clicked or Escaped away
+func NewDetailView(title string, lines []string, width int, height int) *ui.Paragraph {
+	p := ui.NewParagraph(strings.Join(lines, "\n"))
+	p.BorderLabel = title + " (click or Esc to close)"
+	p.BorderFg = FocusBorderColor
+
+	p.Width = 80
+	if p.Width > width-4 {
+		p.Width = width - 4
+	}
+
+	p.Height = len(lines) + 2
+	if p.Height > height-4 {
+		p.Height = height - 4
+	}
+
+	p.X = (width - p.Width) / 2
+	p.Y = (height - p.Height) / 2
+
+	return p
+}
@@ -24,6 +24,7 @@ import (
 const GitRepoStatusUpdateInterval = 10 * time.Second
 
 type RepoStatusField struct {
+	Name              string
 	OutputCharacter   rune
 	OutputColorString string
 }
@@ -32,21 +33,21 @@ type RepoStatusField struct {
 var RepoStatusFieldDefinitionsOrderedKeys = []rune{'M', 'A', 'D', 'R', 'C', 'U', '?', '!'}
 var RepoStatusFieldDefinitions = map[rune]RepoStatusField{
 	// modified
-	'M': RepoStatusField{OutputCharacter: 'M', OutputColorString: "fg-green"},
+	'M': RepoStatusField{Name: "modified", OutputCharacter: 'M', OutputColorString: "fg-green"},
 	// added
-	'A': RepoStatusField{OutputCharacter: '+', OutputColorString: "fg-green,fg-bold"},
+	'A': RepoStatusField{Name: "added", OutputCharacter: '+', OutputColorString: "fg-green,fg-bold"},
 	// deleted
-	'D': RepoStatusField{OutputCharacter: '-', OutputColorString: "fg-red,fg-bold"},
+	'D': RepoStatusField{Name: "deleted", OutputCharacter: '-', OutputColorString: "fg-red,fg-bold"},
 	// renamed
-	'R': RepoStatusField{OutputCharacter: 'R', OutputColorString: "fg-yellow,fg-bold"},
+	'R': RepoStatusField{Name: "renamed", OutputCharacter: 'R', OutputColorString: "fg-yellow,fg-bold"},
 	// copied
-	'C': RepoStatusField{OutputCharacter: 'C', OutputColorString: "fg-blue,fg-bold"},
+	'C': RepoStatusField{Name: "copied", OutputCharacter: 'C', OutputColorString: "fg-blue,fg-bold"},
 	// updated
-	'U': RepoStatusField{OutputCharacter: 'U', OutputColorString: "fg-magenta,fg-bold"},
+	'U': RepoStatusField{Name: "updated", OutputCharacter: 'U', OutputColorString: "fg-magenta,fg-bold"},
 	// untracked
-	'?': RepoStatusField{OutputCharacter: '?', OutputColorString: "fg-red"},
+	'?': RepoStatusField{Name: "untracked", OutputCharacter: '?', OutputColorString: "fg-red"},
 	// ignored
-	'!': RepoStatusField{OutputCharacter: '!', OutputColorString: "fg-cyan"},
+	'!': RepoStatusField{Name: "ignored", OutputCharacter: '!', OutputColorString: "fg-cyan"},
 }
 
 type RepoInfo struct {
@@ -55,6 +56,7 @@ type RepoInfo struct {
 	HomePath     string
 	BranchStatus string
 	Status       string
+	StatusCounts map[rune]int
 	lastUpdated  *time.Time
 }
 
@@ -167,6 +169,7 @@ func (w *RepoInfo) update() {
 				}
 			}
 
+			w.StatusCounts = status
 			w.Status = buildColoredStatusStringFromMap(status)
 		}
 	}
@@ -251,8 +254,8 @@ func (w *CachedGitRepoList) update() {
 	}
 
 	// Update status for all the repos as well
-	for _, r := range w.Repos {
-		r.update()
+	for i := range w.Repos {
+		w.Repos[i].update()
 	}
 }
 
@@ -268,7 +271,16 @@ func NewCachedGitRepoList(search map[string]int) *CachedGitRepoList {
 	return w
 }
 
-var cachedGitRepos = NewCachedGitRepoList(GetGitRepoSearchPaths())
+// Walking the search paths is expensive, so don't do it until something needs the list
+var cachedGitRepos *CachedGitRepoList
+
+func getCachedGitRepos() *CachedGitRepoList {
+	if cachedGitRepos == nil {
+		cachedGitRepos = NewCachedGitRepoList(GetGitRepoSearchPaths())
+	}
+
+	return cachedGitRepos
+}
 
 // Walks the search directories to look for git folders
 // search is a map of directory roots to depths
@@ -376,9 +388,24 @@ func checkAndResolveGitFolder(osPathname string, de *walk.Dirent) *string {
 const MinimumRepoNameWidth = 26
 const MinimumRepoBranchesWidth = 37
 
+// Past this many repos the table scrolls ([ and ], or the mouse wheel)
+const (
+	GitRepoMaxRows        = 20
+	GitRepoCompactMaxRows = 10
+)
+
+const GitRepoScrollUpKey = "["
+const GitRepoScrollDownKey = "]"
+
 type GitRepoWidget struct {
 	widget      *ui.Table
 	lastUpdated *time.Time
+	// Paths get cut down to fit MinimumRepoNameWidth, for small terminals
+	compact bool
+	// Every repo, the table only gets the ones scrolled to
+	repos    []RepoInfo
+	rows     [][]string
+	position ScrollPosition
 }
 
 func NewGitRepoWidget() *GitRepoWidget {
@@ -405,40 +432,139 @@ func (w *GitRepoWidget) getGridWidget() ui.GridBufferer {
 
 func (w *GitRepoWidget) update() {
 	rows := [][]string{}
-	height := 2
 
 	// Load repos
-	cachedGitRepos.update()
+	repoList := getCachedGitRepos()
+	repoList.update()
 
 	maxRepoWidth := 0
 
-	for _, repo := range cachedGitRepos.Repos {
+	for _, repo := range repoList.Repos {
 		// Figure out max length
-		if len(repo.HomePath) > maxRepoWidth {
-			maxRepoWidth = len(repo.HomePath)
+		if displayWidth(repo.HomePath) > maxRepoWidth {
+			maxRepoWidth = displayWidth(repo.HomePath)
 		}
 	}
 
-	if maxRepoWidth < MinimumRepoNameWidth {
+	if maxRepoWidth < MinimumRepoNameWidth || w.compact {
 		maxRepoWidth = MinimumRepoNameWidth
 	}
 
-	for _, repo := range cachedGitRepos.Repos {
+	for _, repo := range repoList.Repos {
 		// Make the name all fancy
-		pathPad := maxRepoWidth - len(repo.Name)
+		pathPad := maxRepoWidth - displayWidth(repo.Name)
 		path := filepath.Dir(repo.HomePath)
 
-		name := fmt.Sprintf("[%*v%c](fg-cyan)[%v](fg-cyan,fg-bold)", pathPad, path, os.PathSeparator, repo.Name)
+		if w.compact {
+			path = truncateStartToWidth(path, pathPad)
+		}
+
+		name := fmt.Sprintf("[%v%c](fg-cyan)[%v](fg-cyan,fg-bold)",
+			escapeMarkupSegment(padLeftToWidth(path, pathPad)), os.PathSeparator, escapeMarkupSegment(repo.Name))
 
 		line := []string{name, repo.BranchStatus, repo.Status}
 
 		rows = append(rows, line)
-		height++
 	}
 
-	w.widget.Rows = rows
-	w.widget.Height = height
+	w.repos = repoList.Repos
+	w.rows = rows
+	w.refreshRows()
+}
+
+func (w *GitRepoWidget) refreshRows() {
+	maxRows := GitRepoMaxRows
+	if w.compact {
+		maxRows = GitRepoCompactMaxRows
+	}
+
+	shown := len(w.rows)
+	if shown > maxRows {
+		shown = maxRows
+	}
+
+	w.position.setSize(shown, len(w.rows))
+
+	w.widget.Rows = w.rows[w.position.offset : w.position.offset+shown]
+	w.widget.Height = shown + 2
+
+	w.widget.BorderLabel = "Git Repos"
+	if indicator := w.position.indicator(); len(indicator) > 0 {
+		w.widget.BorderLabel += " ── " + indicator
+	}
+}
+
+func (w *GitRepoWidget) handleEvent(e ui.Event) bool {
+	moved := false
+
+	switch e.ID {
+	case GitRepoScrollUpKey:
+		moved = w.position.scroll(-w.position.shown)
+	case GitRepoScrollDownKey:
+		moved = w.position.scroll(w.position.shown)
+	case "<MouseWheelUp>", "<MouseWheelDown>":
+		moved = isMouseOver(e, &w.widget.Block) && w.position.handleScrollEvent(e, w.position.shown)
+	}
+
+	if moved {
+		w.refreshRows()
+	}
+
+	return moved
+}
+
+// The status of the repo under the pointer, broken down, with its latest commits
+func (w *GitRepoWidget) getDetailAt(x int, y int) (string, []string, bool) {
+	b := &w.widget.Block
+
+	// One line per row, inside the border
+	row := y - b.Y - 1
+	if x < b.X || x >= b.X+b.Width || row < 0 || row >= len(w.widget.Rows) {
+		return "", nil, false
+	}
+
+	repo := w.repos[w.position.offset+row]
+
+	lines := []string{
+		fmt.Sprintf("Path:   %v", repo.FullPath),
+		fmt.Sprintf("Branch: %v", repo.BranchStatus),
+		"",
+	}
+
+	for _, key := range RepoStatusFieldDefinitionsOrderedKeys {
+		field := RepoStatusFieldDefinitions[key]
+		lines = append(lines, fmt.Sprintf("[%c](%s) %-10v %d", field.OutputCharacter, field.OutputColorString, field.Name, repo.StatusCounts[key]))
+	}
 
+	output, exitCode, err := execAndGetOutput("git", &repo.FullPath, "log", "--oneline", "--no-decorate", "-n", "5")
+	if err != nil || exitCode != 0 {
+		log.Printf("Error getting log for repo %v (exit code %d): %v", repo.FullPath, exitCode, err)
+	} else if commits := strings.TrimSpace(output); len(commits) > 0 {
+		lines = append(lines, "", "Latest commits:")
+		lines = append(lines, strings.Split(commits, "\n")...)
+	}
+
+	return "Repo: " + repo.HomePath, lines, true
+}
+
+func (w *GitRepoWidget) getMetrics() []Metric {
+	metrics := make([]Metric, 0)
+
+	for _, repo := range getCachedGitRepos().Repos {
+		for _, key := range RepoStatusFieldDefinitionsOrderedKeys {
+			metrics = append(metrics, newGaugeMetric("git", "files", "Files in the working tree by git status category.",
+				float64(repo.StatusCounts[key]),
+				MetricLabel{Name: "repo", Value: repo.HomePath},
+				MetricLabel{Name: "status", Value: RepoStatusFieldDefinitions[key].Name}))
+		}
+	}
+
+	return metrics
+}
+
+func (w *GitRepoWidget) setCompact(compact bool) {
+	w.compact = compact
+	w.update()
 }
 
 func (w *GitRepoWidget) resize() {
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"os/user"
+	"time"
 
 	ui "github.com/gizak/termui"
 )
@@ -16,29 +17,24 @@ import (
 // Widget: Header
 ////////////////////////////////////////////
 
+// How long things like "saved a screenshot" stay up
+const HeaderMessageDuration = 10 * time.Second
+
 type HeaderWidget struct {
 	widget         *ui.Paragraph
 	userHostHeader string
+	message        string
+	messageUntil   time.Time
 }
 
 func NewHeaderWidget() *HeaderWidget {
+	return NewHeaderWidgetWithLabel(getUserHostHeader())
+}
+
+func NewHeaderWidgetWithLabel(userHostHeader string) *HeaderWidget {
 	// Create base element
 	e := ui.NewParagraph("")
 	e.BorderFg = ui.ColorCyan | ui.AttrBold
-
-	// Static information
-	userName := getUsername()
-	hostName, prettyName := getHostname()
-	var userHostHeader string
-
-	if prettyName != hostName {
-		// Host/pretty name are different
-		userHostHeader = fmt.Sprintf("%v @ %v (%v)", userName, prettyName, hostName)
-	} else {
-		// Host/pretty name are the same (or pretty failed)
-		userHostHeader = fmt.Sprintf("%v @ %v", userName, hostName)
-	}
-
 	e.BorderLabel = userHostHeader
 
 	// Create our widget
@@ -59,6 +55,17 @@ func (w *HeaderWidget) getGridWidget() ui.GridBufferer {
 }
 
 func (w *HeaderWidget) update() {
+	if len(w.message) > 0 && time.Now().After(w.messageUntil) {
+		w.message = ""
+		w.widget.BorderLabel = w.userHostHeader
+	}
+}
+
+// Next to the user and host for a bit.  Dashboards that set their own label will replace it on their next update.
+func (w *HeaderWidget) showMessage(message string) {
+	w.message = message
+	w.messageUntil = time.Now().Add(HeaderMessageDuration)
+	w.widget.BorderLabel = fmt.Sprintf("%v ── [%v](fg-yellow,fg-bold)", w.userHostHeader, message)
 }
 
 func (w *HeaderWidget) resize() {
@@ -72,6 +79,20 @@ func (w *HeaderWidget) resize() {
 	w.update()
 }
 
+// Static information
+func getUserHostHeader() string {
+	userName := getUsername()
+	hostName, prettyName := getHostname()
+
+	if prettyName != hostName {
+		// Host/pretty name are different
+		return fmt.Sprintf("%v @ %v (%v)", userName, prettyName, hostName)
+	} else {
+		// Host/pretty name are the same (or pretty failed)
+		return fmt.Sprintf("%v @ %v", userName, hostName)
+	}
+}
+
 func getUsername() string {
 	curUser, userErr := user.Current()
 	userName := "unknown"
@@ -0,0 +1,419 @@
+package main
+
+/**
+ * Metric history on disk, so charts have something to show right after a restart and there's a record of yesterday.
+ *
+ * One bucket per metric key.  Keys in a bucket are big-endian nanosecond timestamps (so they sort by time), values are
+ * the float64 bits.  Raw points are only kept for a day.  For longer ranges there are min/max/sum/count rollups over
+ * coarser windows, in buckets named like "60s:cpu.load1".
+ */
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+////////////////////////////////////////////
+// Utility: History Store
+////////////////////////////////////////////
+
+const HistoryPruneInterval = time.Hour
+
+// Another sysdash might have it open
+const HistoryOpenTimeout = time.Second
+
+// Raw points take a lot of room, the rollups cover anything longer
+const HistoryRawRetention = 24 * time.Hour
+
+// Finest first
+var HistoryRollupResolutions = []time.Duration{time.Minute, 15 * time.Minute}
+
+// Everything recorded in one window
+type HistoryRollup struct {
+	Timestamp time.Time
+	Min       float64
+	Max       float64
+	Sum       float64
+	Count     float64
+}
+
+func (r *HistoryRollup) add(value float64) {
+	r.merge(HistoryRollup{Min: value, Max: value, Sum: value, Count: 1})
+}
+
+func (r *HistoryRollup) merge(other HistoryRollup) {
+	if other.Count <= 0 {
+		return
+	}
+
+	if r.Count <= 0 || other.Min < r.Min {
+		r.Min = other.Min
+	}
+
+	if r.Count <= 0 || other.Max > r.Max {
+		r.Max = other.Max
+	}
+
+	r.Sum += other.Sum
+	r.Count += other.Count
+}
+
+func (r HistoryRollup) avg() float64 {
+	if r.Count <= 0 {
+		return 0
+	}
+
+	return r.Sum / r.Count
+}
+
+func rollupBucketName(resolution time.Duration, key string) []byte {
+	return []byte(fmt.Sprintf("%ds:%s", int(resolution.Seconds()), key))
+}
+
+func isRollupBucket(name []byte) bool {
+	for _, resolution := range HistoryRollupResolutions {
+		if strings.HasPrefix(string(name), fmt.Sprintf("%ds:", int(resolution.Seconds()))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func encodeHistoryRollup(r HistoryRollup) []byte {
+	v := make([]byte, 32)
+
+	for i, f := range []float64{r.Min, r.Max, r.Sum, r.Count} {
+		binary.BigEndian.PutUint64(v[i*8:], math.Float64bits(f))
+	}
+
+	return v
+}
+
+func decodeHistoryRollup(k []byte, v []byte) HistoryRollup {
+	f := func(i int) float64 {
+		return math.Float64frombits(binary.BigEndian.Uint64(v[i*8:]))
+	}
+
+	return HistoryRollup{
+		Timestamp: time.Unix(0, int64(binary.BigEndian.Uint64(k))),
+		Min:       f(0),
+		Max:       f(1),
+		Sum:       f(2),
+		Count:     f(3),
+	}
+}
+
+type HistoryStore struct {
+	db         *bolt.DB
+	retention  time.Duration
+	batches    chan metricBatch
+	lastPruned time.Time
+}
+
+func OpenHistoryStore(path string, retention time.Duration) (*HistoryStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: HistoryOpenTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &HistoryStore{
+		db:        db,
+		retention: retention,
+		batches:   make(chan metricBatch, 1),
+	}
+
+	go s.run()
+
+	return s, nil
+}
+
+func encodeHistoryTimestamp(t time.Time) []byte {
+	// Nothing's older than that, and UnixNano doesn't work before it
+	if t.Before(time.Unix(0, 0)) {
+		t = time.Unix(0, 0)
+	}
+
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(t.UnixNano()))
+
+	return b
+}
+
+func decodeTimePoint(k []byte, v []byte) TimePoint {
+	return TimePoint{
+		Timestamp: time.Unix(0, int64(binary.BigEndian.Uint64(k))),
+		Value:     math.Float64frombits(binary.BigEndian.Uint64(v)),
+	}
+}
+
+// A MetricListener.  Writing can be slow, so if the last batch is still going this one gets dropped.
+func (s *HistoryStore) record(timestamp time.Time, metrics []Metric) {
+	select {
+	case s.batches <- metricBatch{timestamp: timestamp, metrics: metrics}:
+	default:
+		log.Printf("History still writing, dropping %d metrics", len(metrics))
+	}
+}
+
+func (s *HistoryStore) run() {
+	for batch := range s.batches {
+		if err := s.write(batch); err != nil {
+			log.Printf("Error writing history: %v", err)
+		}
+
+		if time.Since(s.lastPruned) > HistoryPruneInterval {
+			if err := s.prune(time.Now().Add(-s.retention)); err != nil {
+				log.Printf("Error pruning history: %v", err)
+			}
+
+			s.lastPruned = time.Now()
+		}
+	}
+}
+
+func (s *HistoryStore) write(batch metricBatch) error {
+	k := encodeHistoryTimestamp(batch.timestamp)
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, m := range batch.metrics {
+			bucket, err := tx.CreateBucketIfNotExists([]byte(metricKey(m)))
+			if err != nil {
+				return err
+			}
+
+			v := make([]byte, 8)
+			binary.BigEndian.PutUint64(v, math.Float64bits(m.Value))
+
+			if err := bucket.Put(k, v); err != nil {
+				return err
+			}
+
+			for _, resolution := range HistoryRollupResolutions {
+				if err := s.addToRollup(tx, resolution, batch.timestamp, m); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *HistoryStore) addToRollup(tx *bolt.Tx, resolution time.Duration, timestamp time.Time, m Metric) error {
+	bucket, err := tx.CreateBucketIfNotExists(rollupBucketName(resolution, metricKey(m)))
+	if err != nil {
+		return err
+	}
+
+	k := encodeHistoryTimestamp(timestamp.Truncate(resolution))
+
+	rollup := HistoryRollup{}
+	if v := bucket.Get(k); v != nil {
+		rollup = decodeHistoryRollup(k, v)
+	}
+
+	rollup.add(m.Value)
+
+	return bucket.Put(k, encodeHistoryRollup(rollup))
+}
+
+// Drops everything older than the cutoff (or a day for raw points), and the series that are left empty
+func (s *HistoryStore) prune(cutoff time.Time) error {
+	rawCutoff := time.Now().Add(-HistoryRawRetention)
+	if rawCutoff.Before(cutoff) {
+		rawCutoff = cutoff
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		emptyBuckets := make([][]byte, 0)
+
+		err := tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+			end := encodeHistoryTimestamp(rawCutoff)
+			if isRollupBucket(name) {
+				end = encodeHistoryTimestamp(cutoff)
+			}
+
+			// Deleting while walking a cursor skips things, so collect first
+			old := make([][]byte, 0)
+			c := bucket.Cursor()
+
+			for k, _ := c.First(); k != nil && string(k) < string(end); k, _ = c.Next() {
+				old = append(old, append([]byte{}, k...))
+			}
+
+			for _, k := range old {
+				if err := bucket.Delete(k); err != nil {
+					return err
+				}
+			}
+
+			if k, _ := bucket.Cursor().First(); k == nil {
+				emptyBuckets = append(emptyBuckets, append([]byte{}, name...))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, name := range emptyBuckets {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Oldest first
+func (s *HistoryStore) query(key string, since time.Time) ([]TimePoint, error) {
+	points := make([]TimePoint, 0)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(key))
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+
+		for k, v := c.Seek(encodeHistoryTimestamp(since)); k != nil; k, v = c.Next() {
+			points = append(points, decodeTimePoint(k, v))
+		}
+
+		return nil
+	})
+
+	return points, err
+}
+
+// Squeezes from..until into at most slots rollups, oldest first, leaving out the slots with nothing in them.  Reads the
+// coarsest rollups that still have a point for every slot, or the raw points for short ranges.
+func (s *HistoryStore) queryRollups(key string, from time.Time, until time.Time, slots int) ([]HistoryRollup, error) {
+	if slots <= 0 || !until.After(from) {
+		return []HistoryRollup{}, nil
+	}
+
+	slotDuration := until.Sub(from) / time.Duration(slots)
+
+	bucketName := []byte(key)
+	isRollup := false
+
+	for _, resolution := range HistoryRollupResolutions {
+		if resolution <= slotDuration {
+			bucketName = rollupBucketName(resolution, key)
+			isRollup = true
+		}
+	}
+
+	merged := make([]HistoryRollup, slots)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketName)
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		end := string(encodeHistoryTimestamp(until))
+
+		for k, v := c.Seek(encodeHistoryTimestamp(from)); k != nil && string(k) < end; k, v = c.Next() {
+			var rollup HistoryRollup
+
+			if isRollup {
+				rollup = decodeHistoryRollup(k, v)
+			} else {
+				p := decodeTimePoint(k, v)
+				rollup = HistoryRollup{Timestamp: p.Timestamp}
+				rollup.add(p.Value)
+			}
+
+			slot := int(rollup.Timestamp.Sub(from) / slotDuration)
+			if slot < 0 || slot >= slots {
+				continue
+			}
+
+			merged[slot].merge(rollup)
+		}
+
+		return nil
+	})
+
+	rollups := make([]HistoryRollup, 0, slots)
+
+	for i, rollup := range merged {
+		if rollup.Count > 0 {
+			rollup.Timestamp = from.Add(time.Duration(i) * slotDuration)
+			rollups = append(rollups, rollup)
+		}
+	}
+
+	return rollups, err
+}
+
+func (s *HistoryStore) Close() {
+	s.db.Close()
+}
+
+// Nil unless startHistory managed to open it
+var metricHistory *HistoryStore
+
+// For the commands that keep collecting, before their widgets exist so they can load what came before
+func startHistory() {
+	path := GetHistoryPath()
+	if len(path) <= 0 {
+		return
+	}
+
+	store, err := OpenHistoryStore(path, GetHistoryRetention())
+	if err != nil {
+		log.Printf("Not keeping history in '%v': %v", path, err)
+		return
+	}
+
+	metricHistory = store
+	latestMetrics.addListener(store.record)
+}
+
+// Empty when there's no history
+func queryHistory(key string, since time.Time) []TimePoint {
+	if metricHistory == nil {
+		return []TimePoint{}
+	}
+
+	points, err := metricHistory.query(key, since)
+	if err != nil {
+		log.Printf("Error reading history for '%v': %v", key, err)
+	}
+
+	return points
+}
+
+// Empty when there's no history
+func queryHistoryRollups(key string, from time.Time, until time.Time, slots int) []HistoryRollup {
+	if metricHistory == nil {
+		return []HistoryRollup{}
+	}
+
+	rollups, err := metricHistory.queryRollups(key, from, until, slots)
+	if err != nil {
+		log.Printf("Error reading history rollups for '%v': %v", key, err)
+	}
+
+	return rollups
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func openHistoryTestStore(t *testing.T) *HistoryStore {
+	s, err := OpenHistoryStore(filepath.Join(t.TempDir(), "history.db"), DefaultHistoryRetention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Straight to the file, skipping the channel record goes through
+func writeHistoryTestPoint(t *testing.T, s *HistoryStore, timestamp time.Time, key string, value float64) {
+	if err := s.write(metricBatch{timestamp: timestamp, metrics: []Metric{newGaugeMetric("test", key, "", value)}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHistoryRollupAdd(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   HistoryRollup
+	}{
+		{[]float64{}, HistoryRollup{}},
+		{[]float64{5}, HistoryRollup{Min: 5, Max: 5, Sum: 5, Count: 1}},
+		{[]float64{3, 1, 2}, HistoryRollup{Min: 1, Max: 3, Sum: 6, Count: 3}},
+		// Nothing to do with the zero value it starts from
+		{[]float64{-2, -4}, HistoryRollup{Min: -4, Max: -2, Sum: -6, Count: 2}},
+		{[]float64{4, 8}, HistoryRollup{Min: 4, Max: 8, Sum: 12, Count: 2}},
+	}
+
+	for _, test := range tests {
+		var r HistoryRollup
+		for _, v := range test.values {
+			r.add(v)
+		}
+
+		if r != test.want {
+			t.Errorf("%v: got %+v, want %+v", test.values, r, test.want)
+		}
+	}
+
+	// An empty one changes nothing
+	r := HistoryRollup{Min: 1, Max: 2, Sum: 3, Count: 2}
+	r.merge(HistoryRollup{})
+
+	if r != (HistoryRollup{Min: 1, Max: 2, Sum: 3, Count: 2}) || r.avg() != 1.5 {
+		t.Errorf("after merging nothing got %+v", r)
+	}
+}
+
+func TestHistoryStoreQueryRollups(t *testing.T) {
+	s := openHistoryTestStore(t)
+
+	// 0, 1, 2... every 30s for 10 minutes
+	for i := 0; i < 20; i++ {
+		writeHistoryTestPoint(t, s, timeSeriesTestStart.Add(time.Duration(i)*30*time.Second), "load", float64(i))
+	}
+
+	at := func(d time.Duration) time.Time {
+		return timeSeriesTestStart.Add(d)
+	}
+
+	tests := []struct {
+		name  string
+		key   string
+		from  time.Time
+		until time.Time
+		slots int
+		want  []HistoryRollup
+	}{
+		{"no slots", "test.load", at(0), at(time.Minute), 0, []HistoryRollup{}},
+		{"backwards", "test.load", at(time.Minute), at(0), 2, []HistoryRollup{}},
+		{"unknown key", "test.nothing", at(0), at(time.Minute), 2, []HistoryRollup{}},
+		{"raw points a slot each", "test.load", at(0), at(time.Minute), 2, []HistoryRollup{
+			{Timestamp: at(0), Min: 0, Max: 0, Sum: 0, Count: 1},
+			{Timestamp: at(30 * time.Second), Min: 1, Max: 1, Sum: 1, Count: 1},
+		}},
+		{"raw points merged", "test.load", at(0), at(100 * time.Second), 2, []HistoryRollup{
+			{Timestamp: at(0), Min: 0, Max: 1, Sum: 1, Count: 2},
+			{Timestamp: at(50 * time.Second), Min: 2, Max: 3, Sum: 5, Count: 2},
+		}},
+		{"until left out", "test.load", at(0), at(30 * time.Second), 1, []HistoryRollup{
+			{Timestamp: at(0), Min: 0, Max: 0, Sum: 0, Count: 1},
+		}},
+		{"minute rollups", "test.load", at(0), at(10 * time.Minute), 5, []HistoryRollup{
+			{Timestamp: at(0), Min: 0, Max: 3, Sum: 6, Count: 4},
+			{Timestamp: at(2 * time.Minute), Min: 4, Max: 7, Sum: 22, Count: 4},
+			{Timestamp: at(4 * time.Minute), Min: 8, Max: 11, Sum: 38, Count: 4},
+			{Timestamp: at(6 * time.Minute), Min: 12, Max: 15, Sum: 54, Count: 4},
+			{Timestamp: at(8 * time.Minute), Min: 16, Max: 19, Sum: 70, Count: 4},
+		}},
+		{"empty slots left out", "test.load", at(-10 * time.Minute), at(10 * time.Minute), 2, []HistoryRollup{
+			{Timestamp: at(0), Min: 0, Max: 19, Sum: 190, Count: 20},
+		}},
+	}
+
+	for _, test := range tests {
+		got, err := s.queryRollups(test.key, test.from, test.until, test.slots)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestHistoryStorePrune(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		// Before now
+		cutoff      time.Duration
+		wantRaw     []float64
+		wantKeys    []string
+		wantBuckets int
+	}{
+		// Raw points never outlast a day, the rollups go back to the cutoff
+		{"week", 7 * 24 * time.Hour, []float64{3, 4}, []string{"test.old", "test.recent"}, 5},
+		{"two days", 48 * time.Hour, []float64{3, 4}, []string{"test.recent"}, 3},
+		{"six hours", 6 * time.Hour, []float64{4}, []string{"test.recent"}, 3},
+		{"everything", 0, []float64{}, []string{}, 0},
+	}
+
+	for _, test := range tests {
+		s := openHistoryTestStore(t)
+
+		writeHistoryTestPoint(t, s, now.Add(-72*time.Hour), "old", 1)
+		writeHistoryTestPoint(t, s, now.Add(-36*time.Hour), "recent", 2)
+		writeHistoryTestPoint(t, s, now.Add(-12*time.Hour), "recent", 3)
+		writeHistoryTestPoint(t, s, now.Add(-time.Hour), "recent", 4)
+
+		if err := s.prune(now.Add(-test.cutoff)); err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+
+		points, err := s.query("test.recent", time.Time{})
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+
+		if got := pointValues(points); !reflect.DeepEqual(got, test.wantRaw) {
+			t.Errorf("%v: raw points are %v, want %v", test.name, got, test.wantRaw)
+		}
+
+		keys := make([]string, 0)
+		for _, key := range []string{"test.old", "test.recent"} {
+			if len(queryHistoryTestRollups(t, s, key, now)) > 0 {
+				keys = append(keys, key)
+			}
+		}
+
+		if !reflect.DeepEqual(keys, test.wantKeys) {
+			t.Errorf("%v: series with rollups are %v, want %v", test.name, keys, test.wantKeys)
+		}
+
+		// Buckets left empty go too
+		buckets := 0
+		s.db.View(func(tx *bolt.Tx) error {
+			return tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+				buckets++
+				return nil
+			})
+		})
+
+		if buckets != test.wantBuckets {
+			t.Errorf("%v: %d buckets left, want %d", test.name, buckets, test.wantBuckets)
+		}
+	}
+}
+
+// Everything in the last week, squeezed into one slot
+func queryHistoryTestRollups(t *testing.T, s *HistoryStore, key string, now time.Time) []HistoryRollup {
+	rollups, err := s.queryRollups(key, now.Add(-7*24*time.Hour), now, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rollups
+}
@@ -33,7 +33,9 @@ func getTime() (time.Time, *linuxproc.Uptime) {
 ////////////////////////////////////////////
 
 type HostInfoWidget struct {
-	widget *ui.List
+	widget            *ui.List
+	uptime            *linuxproc.Uptime
+	hasKerberosTicket bool
 }
 
 func NewHostInfoWidget() *HostInfoWidget {
@@ -60,7 +62,10 @@ func (w *HostInfoWidget) getGridWidget() ui.GridBufferer {
 
 func (w *HostInfoWidget) update() {
 	now, uptime := getTime()
-	krbText, krbAttr := getKerberosStatusString()
+	krbText, krbAttr, hasTicket := getKerberosStatusString()
+
+	w.uptime = uptime
+	w.hasKerberosTicket = hasTicket
 
 	// Start building lines
 	w.widget.Items = []string{}
@@ -80,7 +85,19 @@ func (w *HostInfoWidget) resize() {
 	// Do nothing
 }
 
-func getKerberosStatusString() (string, string) {
+func (w *HostInfoWidget) getMetrics() []Metric {
+	metrics := []Metric{
+		newGaugeMetric("kerberos", "ticket_valid", "Whether there is a valid kerberos ticket (1) or not (0).", boolToMetricValue(w.hasKerberosTicket)),
+	}
+
+	if w.uptime != nil {
+		metrics = append(metrics, newGaugeMetric("host", "uptime_seconds", "Seconds since boot.", w.uptime.Total))
+	}
+
+	return metrics
+}
+
+func getKerberosStatusString() (string, string, bool) {
 	// Do we have a ticket?
 	_, exitCode, _ := execAndGetOutput("klist", nil, "-s")
 
@@ -109,11 +126,11 @@ func getKerberosStatusString() (string, string) {
 		} else {
 			krbText = fmt.Sprintf("OK")
 		}
-		krbAttrStr = "fg-green,fg-bold"
+		krbAttrStr = severityToColorString(SeverityOK)
 	} else {
 		krbText = fmt.Sprintf("NO TICKET")
-		krbAttrStr = "fg-red,fg-bold"
+		krbAttrStr = severityToColorString(SeverityCritical)
 	}
 
-	return krbText, krbAttrStr
+	return krbText, krbAttrStr, hasTicket
 }
@@ -0,0 +1,444 @@
+package main
+
+/**
+ * Several hosts on one screen.  Runs `sysdash --once --format=json` on each of them over SSH, shows a row per host,
+ * and can drill down into any one host's full dashboard.
+ */
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	ui "github.com/gizak/termui"
+)
+
+////////////////////////////////////////////
+// Utility: Remote Host
+////////////////////////////////////////////
+
+const RemoteHostUpdateInterval = 30 * time.Second
+
+type RemoteHost struct {
+	name        string
+	lock        sync.Mutex
+	message     *AgentMessage
+	lastError   error
+	lastUpdated time.Time
+}
+
+func NewRemoteHost(name string) *RemoteHost {
+	return &RemoteHost{name: name}
+}
+
+// Blocks until ssh is done
+func (h *RemoteHost) refresh() {
+	// Never prompt for anything, there's no terminal for it
+	output, stderr, exitCode, err := execAndGetOutputAndError("ssh", nil, "-o", "BatchMode=yes", "-o", "ConnectTimeout=10",
+		h.name, GetRemoteCommand())
+
+	var message AgentMessage
+	if err == nil {
+		err = json.Unmarshal([]byte(output), &message)
+	}
+
+	if err != nil {
+		err = describeRemoteHostError(output, stderr, exitCode, err)
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if err != nil {
+		log.Printf("Error collecting from remote host '%v': %v", h.name, err)
+		h.lastError = err
+	} else {
+		h.message = &message
+		h.lastError = nil
+		h.lastUpdated = time.Now()
+	}
+}
+
+// What ssh (or sysdash on the other end) said about it, since "exit status 255" alone doesn't say much
+func describeRemoteHostError(output string, stderr string, exitCode int, err error) error {
+	var message string
+	switch {
+	case exitCode != 0:
+		message = fmt.Sprintf("ssh failed: %v", err)
+	case strings.TrimSpace(output) == "":
+		message = "no output"
+	default:
+		message = fmt.Sprintf("couldn't parse the output: %v", err)
+	}
+
+	stderr = strings.Join(strings.Fields(stderr), " ")
+	if stderr != "" {
+		message += ": " + stderr
+	}
+
+	return errors.New(message)
+}
+
+// The message is nil until the first successful refresh
+func (h *RemoteHost) get() (*AgentMessage, time.Time, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.message, h.lastUpdated, h.lastError
+}
+
+////////////////////////////////////////////
+// Widget: Hosts
+////////////////////////////////////////////
+
+type HostsWidget struct {
+	widget   *ui.Table
+	hosts    []*RemoteHost
+	selected int
+}
+
+func NewHostsWidget(hosts []*RemoteHost) *HostsWidget {
+	// Create base element
+	e := ui.NewTable()
+	e.Border = true
+	e.BorderLabel = "Hosts"
+	e.Separator = false
+
+	// Create widget
+	w := &HostsWidget{
+		widget: e,
+		hosts:  hosts,
+	}
+
+	w.update()
+	w.resize()
+
+	return w
+}
+
+func (w *HostsWidget) getGridWidget() ui.GridBufferer {
+	return w.widget
+}
+
+func (w *HostsWidget) update() {
+	rows := [][]string{{"", "Host", "Updated", "CPU", "5m Load", "Disk", "Kerberos", "Repos"}}
+
+	for i, host := range w.hosts {
+		marker := ""
+		if i == w.selected {
+			marker = "[▶](fg-cyan,fg-bold)"
+		}
+
+		rows = append(rows, append([]string{marker, host.name}, buildHostSummaryCells(host)...))
+	}
+
+	w.widget.Rows = rows
+	w.widget.Height = len(rows) + 2
+}
+
+func (w *HostsWidget) resize() {
+	// Update
+}
+
+func (w *HostsWidget) moveSelection(delta int) {
+	w.selected += delta
+
+	if w.selected < 0 {
+		w.selected = 0
+	} else if w.selected >= len(w.hosts) {
+		w.selected = len(w.hosts) - 1
+	}
+}
+
+func (w *HostsWidget) getSelected() *RemoteHost {
+	return w.hosts[w.selected]
+}
+
+// Everything after the host name: updated, CPU, load, disk, kerberos and repos
+func buildHostSummaryCells(host *RemoteHost) []string {
+	message, lastUpdated, err := host.get()
+
+	if message == nil {
+		if err != nil {
+			return []string{fmt.Sprintf("[failed](%s)", severityToColorString(SeverityCritical)), "", "", "", "", ""}
+		}
+
+		return []string{fmt.Sprintf("[waiting](%s)", severityToColorString(SeverityWarning)), "", "", "", "", ""}
+	}
+
+	updated := fmt.Sprintf("%v ago", time.Since(lastUpdated).Truncate(time.Second))
+	if err != nil {
+		// Still show the last good data, but make it obvious it's old
+		updated = fmt.Sprintf("[%v](%s)", withSeverityMarker(updated, SeverityCritical), severityToColorString(SeverityCritical))
+	}
+
+	summary := summarizeMetrics(message.Metrics)
+
+	cpu := fmt.Sprintf("[%v](%s)",
+		withSeverityMarker(fmt.Sprintf("%0.1f%%", summary.CPUPercent), percentToSeverity(int(summary.CPUPercent), 0, 100, true)),
+		percentToAttributeString(int(summary.CPUPercent), 0, 100, true))
+
+	load := fmt.Sprintf("%0.2f", summary.Load5)
+	if summary.Processors > 0 {
+		loadPercent := 100 * summary.Load5 / float64(summary.Processors)
+		load = fmt.Sprintf("[%s](%s)", withSeverityMarker(load, percentToSeverity(int(loadPercent), 0, 100, true)),
+			percentToAttributeString(int(loadPercent), 0, 100, true))
+	}
+
+	disk := "-"
+	if summary.HasDisks {
+		disk = fmt.Sprintf("[%v](%s)",
+			withSeverityMarker(fmt.Sprintf("%v %0.0f%% free", summary.LowestDiskMount, summary.LowestDiskFree),
+				percentToSeverity(int(summary.LowestDiskFree), 0, 100, false)),
+			percentToAttributeString(int(summary.LowestDiskFree), 0, 100, false))
+	}
+
+	kerberos := "-"
+	if summary.HasKerberos {
+		if summary.KerberosValid {
+			kerberos = fmt.Sprintf("[OK](%s)", severityToColorString(SeverityOK))
+		} else {
+			kerberos = fmt.Sprintf("[expired](%s)", severityToColorString(SeverityCritical))
+		}
+	}
+
+	repos := fmt.Sprintf("%d", summary.Repos)
+	if summary.DirtyRepos > 0 {
+		repos = fmt.Sprintf("[%d/%d dirty](%s)", summary.DirtyRepos, summary.Repos, severityToColorString(SeverityWarning))
+	}
+
+	return []string{updated, cpu, load, disk, kerberos, repos}
+}
+
+////////////////////////////////////////////
+// Utility: Hosts Dashboard
+////////////////////////////////////////////
+
+// Shows the hosts table, or one host's dashboard when drilled down
+type HostsDashboard struct {
+	header  *HeaderWidget
+	list    *HostsWidget
+	changes chan bool
+
+	// Only while drilled down
+	lock       sync.Mutex
+	detailHost *RemoteHost
+	detail     *RemoteDashboard
+}
+
+func NewHostsDashboard(hostNames []string) *HostsDashboard {
+	hosts := make([]*RemoteHost, len(hostNames))
+
+	for i, name := range hostNames {
+		hosts[i] = NewRemoteHost(name)
+	}
+
+	return &HostsDashboard{
+		header:  NewHeaderWidgetWithLabel(getUserHostHeader()),
+		list:    NewHostsWidget(hosts),
+		changes: make(chan bool, 1),
+	}
+}
+
+// Keeps every host refreshing in the background
+func (d *HostsDashboard) start() {
+	for _, host := range d.list.hosts {
+		go d.follow(host)
+	}
+}
+
+func (d *HostsDashboard) follow(host *RemoteHost) {
+	for {
+		host.refresh()
+
+		if message, _, _ := host.get(); message != nil {
+			d.lock.Lock()
+			if d.detailHost == host {
+				d.detail.setState(message.State)
+			}
+			d.lock.Unlock()
+		}
+
+		select {
+		case d.changes <- true:
+		default:
+		}
+
+		time.Sleep(RemoteHostUpdateInterval)
+	}
+}
+
+func (d *HostsDashboard) getDetail() *RemoteDashboard {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.detail
+}
+
+func (d *HostsDashboard) getHeader() *HeaderWidget {
+	if detail := d.getDetail(); detail != nil {
+		return detail.getHeader()
+	}
+
+	return d.header
+}
+
+func (d *HostsDashboard) getWidgets() []CAHWidget {
+	if detail := d.getDetail(); detail != nil {
+		return detail.getWidgets()
+	}
+
+	return []CAHWidget{d.header, d.list}
+}
+
+func (d *HostsDashboard) getChanges() <-chan bool {
+	return d.changes
+}
+
+func (d *HostsDashboard) afterUpdate() {
+	if detail := d.getDetail(); detail != nil {
+		detail.afterUpdate()
+		detail.header.widget.BorderLabel += " (Esc: all hosts)"
+	} else {
+		d.header.widget.BorderLabel = fmt.Sprintf("%v (%d hosts, Enter: open)", d.header.userHostHeader, len(d.list.hosts))
+	}
+}
+
+func (d *HostsDashboard) handleEvent(e ui.Event) bool {
+	if d.getDetail() != nil {
+		if e.ID == "<Escape>" {
+			d.closeDetail()
+			return true
+		}
+
+		return false
+	}
+
+	switch e.ID {
+	case "<Up>", "k":
+		d.list.moveSelection(-1)
+		return true
+	case "<Down>", "j":
+		d.list.moveSelection(1)
+		return true
+	case "<Enter>":
+		return d.openDetail(d.list.getSelected())
+	}
+
+	return false
+}
+
+// Nothing to show until the host has answered once
+func (d *HostsDashboard) openDetail(host *RemoteHost) bool {
+	message, _, _ := host.get()
+	if message == nil {
+		return false
+	}
+
+	detail := NewRemoteDashboard(message.State)
+	detail.setTerminalSize(ui.TermWidth(), ui.TermHeight())
+
+	d.lock.Lock()
+	d.detailHost = host
+	d.detail = detail
+	d.lock.Unlock()
+
+	return true
+}
+
+func (d *HostsDashboard) closeDetail() {
+	d.lock.Lock()
+	d.detailHost = nil
+	d.detail = nil
+	d.lock.Unlock()
+}
+
+// The table fits anywhere, only the drilled down dashboard changes
+func (d *HostsDashboard) setTerminalSize(width int, height int) bool {
+	if detail := d.getDetail(); detail != nil {
+		return detail.setTerminalSize(width, height)
+	}
+
+	return false
+}
+
+func (d *HostsDashboard) buildRows() []*ui.Row {
+	if detail := d.getDetail(); detail != nil {
+		return detail.buildRows()
+	}
+
+	return []*ui.Row{ui.NewRow(ui.NewCol(12, 0, d.list.getGridWidget()))}
+}
+
+// Only the drilled down dashboard has widgets to focus
+func (d *HostsDashboard) getFocusNames() []string {
+	if detail := d.getDetail(); detail != nil {
+		return detail.getFocusNames()
+	}
+
+	return []string{}
+}
+
+func (d *HostsDashboard) getGridWidget(name string) ui.GridBufferer {
+	if detail := d.getDetail(); detail != nil {
+		return detail.getGridWidget(name)
+	}
+
+	return nil
+}
+
+func (d *HostsDashboard) getDetailAt(x int, y int) (string, []string, bool) {
+	if detail := d.getDetail(); detail != nil {
+		return detail.getDetailAt(x, y)
+	}
+
+	return "", nil, false
+}
+
+func (d *HostsDashboard) handleFocusedEvent(name string, e ui.Event) bool {
+	if detail := d.getDetail(); detail != nil {
+		return detail.handleFocusedEvent(name, e)
+	}
+
+	return false
+}
+
+////////////////////////////////////////////
+// Commands: hosts
+////////////////////////////////////////////
+
+func runHosts() {
+	hostNames := GetRemoteHosts()
+
+	if len(hostNames) == 0 {
+		fmt.Fprintf(os.Stderr, "No remote hosts configured, set -hosts or SYSDASH_REMOTE_HOSTS\n")
+		os.Exit(2)
+	}
+
+	// Set up the console UI
+	uiErr := ui.Init()
+	if uiErr != nil {
+		panic(uiErr)
+	}
+	defer ui.Close()
+
+	initTerminalColors(GetColorMode())
+
+	dashboard := NewHostsDashboard(hostNames)
+	dashboard.start()
+
+	// Give space around the ui.Body for the header box to wrap all around
+	ui.Body.Width = ui.TermWidth() - 2
+	ui.Body.X = 1
+	ui.Body.Y = 1
+
+	ui.Body.AddRows(dashboard.buildRows()...)
+	ui.Body.Align()
+
+	loop(dashboard)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDescribeRemoteHostError(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		stderr   string
+		exitCode int
+		err      error
+		want     string
+	}{
+		{"ssh failed", "", "ssh: Could not resolve hostname web9: Name or service not known\r\n", 255,
+			errors.New("exit status 255"),
+			"ssh failed: exit status 255: ssh: Could not resolve hostname web9: Name or service not known"},
+		{"remote command failed", "", "bash: sysdash: command not found\n", 127, errors.New("exit status 127"),
+			"ssh failed: exit status 127: bash: sysdash: command not found"},
+		{"no output", "\n", "", 0, errors.New("unexpected end of JSON input"), "no output"},
+		{"output that isn't JSON", "Welcome to web1!\n", "warning: old\nversion\n", 0,
+			errors.New("invalid character 'W' looking for beginning of value"),
+			"couldn't parse the output: invalid character 'W' looking for beginning of value: warning: old version"},
+	}
+
+	for _, test := range tests {
+		err := describeRemoteHostError(test.output, test.stderr, test.exitCode, test.err)
+
+		if err.Error() != test.want {
+			t.Errorf("%v: got %q, want %q", test.name, err.Error(), test.want)
+		}
+	}
+}
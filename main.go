@@ -24,7 +24,7 @@ package main
  *      - CPU
  *          - Load average w/ color
  *          - Percentage
- *          - Top processes?
+ *      - Top processes
  *      - Status of git repos
  *
  * Minimum terminal size to support:
@@ -36,6 +36,8 @@ package main
  */
 
 import (
+	"flag"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
@@ -48,17 +50,61 @@ import (
 // Rendering loop
 //
 
-func loop(widgets []CAHWidget, header *HeaderWidget) {
+func loop(dashboard DashboardView) {
+	// Inside the header's border, which gets the scroll bar
+	viewport := NewViewport(ui.Body)
+	viewport.setArea(1, 1, ui.TermWidth()-2, ui.TermHeight()-2)
+
+	colorMode := GetColorMode()
+
+	// Clicked on, or zoomed in on
+	focus := NewWidgetFocus()
+	// Over the top of everything, when something's been clicked on for its details
+	var detail *ui.Paragraph
+
+	rebuild := func() {
+		ui.Body.Rows = focus.buildRows(dashboard, viewport.Height)
+	}
+
 	render := func() {
-		ui.Body.Align()
+		viewport.align()
 		ui.Clear()
-		ui.Render(header.widget, ui.Body)
+
+		if detail != nil {
+			ui.Render(adaptColors(colorMode, dashboard.getHeader().widget, viewport, detail)...)
+		} else {
+			ui.Render(adaptColors(colorMode, dashboard.getHeader().widget, viewport)...)
+		}
+	}
+
+	update := func() {
+		// The widgets put their own colors back, then focusing changes them again
+		focus.restore()
+
+		for _, w := range dashboard.getWidgets() {
+			w.update()
+		}
+
+		dashboard.afterUpdate()
+		rebuild()
+		render()
 	}
 
 	//
 	//  Activate
 	//
 
+	// Switches layouts when the terminal gets big or small enough, true if it did
+	relayout := func(width int, height int) bool {
+		changed := dashboard.setTerminalSize(width, height)
+
+		// Zoomed widgets fill the terminal, whatever size it is
+		rebuild()
+
+		return changed
+	}
+
+	relayout(ui.TermWidth(), ui.TermHeight())
 	render()
 	firstTimeResize := false
 	ticker := time.NewTicker(5 * time.Second)
@@ -67,12 +113,11 @@ func loop(widgets []CAHWidget, header *HeaderWidget) {
 		// Call all resize funcs (only the first time)
 		if !firstTimeResize {
 			firstTimeResize = true
-			for _, w := range widgets {
+			for _, w := range dashboard.getWidgets() {
 				w.resize()
-				w.update()
 			}
 
-			render()
+			update()
 		}
 
 		select {
@@ -80,26 +125,105 @@ func loop(widgets []CAHWidget, header *HeaderWidget) {
 			switch e.ID {
 			case "q", "<C-c>":
 				return
+			case ScreenshotKey:
+				header := dashboard.getHeader()
+				path, err := saveScreenshot(GetScreenshotFormat(), ui.TermWidth(), ui.TermHeight(), header.widget, viewport)
+
+				if err != nil {
+					log.Printf("Error saving screenshot: %v", err)
+					header.showMessage("Screenshot failed")
+				} else {
+					header.showMessage("Saved " + path)
+				}
+
+				render()
 			case "<Resize>":
 				payload := e.Payload.(ui.Resize)
 
 				// Re-layout on resize
 				ui.Body.Width = payload.Width - 2
+				viewport.setArea(1, 1, payload.Width-2, payload.Height-2)
+				changed := relayout(payload.Width, payload.Height)
 
 				// Call all resize funcs
-				for _, w := range widgets {
+				for _, w := range dashboard.getWidgets() {
+					w.resize()
+				}
+
+				// Details were sized for the old terminal
+				detail = nil
+
+				// Re-render, with compact versions filled in if those changed
+				if changed {
+					update()
+				} else {
+					render()
+				}
+			case "<MouseLeft>":
+				x, y, _ := getMousePosition(e)
+				wasFocused := focus.isFocusedAt(dashboard, x, y)
+
+				if focus.click(dashboard, x, y) {
+					// Double clicked, so the first click's details are in the way
+					detail = nil
+				} else if detail != nil {
+					detail = nil
+				} else if wasFocused {
+					if title, lines, ok := dashboard.getDetailAt(x, y); ok {
+						detail = NewDetailView(title, lines, ui.TermWidth(), ui.TermHeight())
+					}
+				}
+
+				rebuild()
+
+				// Zooming changes what size everything is
+				for _, w := range dashboard.getWidgets() {
 					w.resize()
 				}
 
-				// Re-render
 				render()
+			case "<Escape>":
+				// Details first, then zooming, then whatever the dashboard does with it
+				if detail != nil {
+					detail = nil
+					render()
+				} else if focus.unzoom() {
+					rebuild()
+
+					for _, w := range dashboard.getWidgets() {
+						w.resize()
+					}
+
+					render()
+				} else if dashboard.handleEvent(e) {
+					for _, w := range dashboard.getWidgets() {
+						w.resize()
+					}
+
+					update()
+				}
+			default:
+				handled := false
+				if name, focused := focus.getFocusName(dashboard); focused {
+					handled = dashboard.handleFocusedEvent(name, e)
+				}
+
+				if handled || dashboard.handleEvent(e) {
+					// Might be different widgets now, size and fill them in
+					for _, w := range dashboard.getWidgets() {
+						w.resize()
+					}
+
+					update()
+				} else if viewport.handleEvent(e) {
+					// Nothing wanted it, so it scrolls the whole thing
+					render()
+				}
 			}
+		case <-dashboard.getChanges():
+			update()
 		case <-ticker.C:
-			for _, w := range widgets {
-				w.update()
-			}
-
-			render()
+			update()
 		}
 	}
 }
@@ -108,22 +232,33 @@ func loop(widgets []CAHWidget, header *HeaderWidget) {
 // Where the real stuff happens
 ////////////////////////////////////////////
 
-func main() {
-	// Set up logging?
-	if LogToFile() {
-		logFile, logErr := os.OpenFile("go.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0660)
-		if logErr != nil {
-			panic(logErr)
+// Exporters get their data from whatever is recording to latestMetrics/latestDashboardState
+func startExporters() {
+	if listen := GetMetricsListenAddress(); len(listen) > 0 {
+		startPrometheusExporter(listen)
+	}
+
+	if target := GetMetricsPushTarget(); len(target) > 0 {
+		pusher, pushErr := NewMetricPusher(target, GetMetricsPushFormat(), GetMetricsPushPrefix(), GetMetricsPushTags())
+
+		if pushErr != nil {
+			log.Printf("Error setting up metrics push: %v", pushErr)
+		} else {
+			latestMetrics.addListener(pusher.push)
 		}
-		defer logFile.Close()
+	}
 
-		log.SetOutput(logFile)
-	} else {
-		// Disable logging
-		log.SetFlags(0)
-		log.SetOutput(ioutil.Discard)
+	if broker := GetMQTTBroker(); len(broker) > 0 {
+		publisher := NewMQTTPublisher(broker, GetMQTTPrefix(), GetMQTTDiscoveryPrefix())
+		latestMetrics.addListener(publisher.publish)
 	}
 
+	if httpAddress := GetWebDashboardAddress(); len(httpAddress) > 0 {
+		startWebDashboard(httpAddress)
+	}
+}
+
+func runDashboard() {
 	// Set up the console UI
 	uiErr := ui.Init()
 	if uiErr != nil {
@@ -131,46 +266,15 @@ func main() {
 	}
 	defer ui.Close()
 
+	initTerminalColors(GetColorMode())
+
+	// Before the widgets, so they can load what came before
+	startHistory()
+
 	//
 	// Create the widgets
 	//
-	widgets := make([]CAHWidget, 0)
-
-	header := NewHeaderWidget()
-	widgets = append(widgets, header)
-
-	hostInfo := NewHostInfoWidget()
-	widgets = append(widgets, hostInfo)
-
-	network := NewNetworkWidget()
-	widgets = append(widgets, network)
-
-	battery := NewBatteryWidget()
-	widgets = append(widgets, battery)
-
-	audio := NewAudioWidget()
-	widgets = append(widgets, audio)
-
-	disk := NewDiskColumn(6, 0)
-	widgets = append(widgets, disk)
-
-	cpu := NewCPUWidget()
-	widgets = append(widgets, cpu)
-
-	repo := NewGitRepoWidget()
-	widgets = append(widgets, repo)
-
-	twitter1 := NewTwitterWidget(GetTwitterAccount1(), ui.ColorBlue|ui.AttrBold)
-	widgets = append(widgets, twitter1)
-
-	twitter2 := NewTwitterWidget(GetTwitterAccount2(), ui.ColorCyan)
-	widgets = append(widgets, twitter2)
-
-	twitter3 := NewTwitterWidget(GetTwitterAccount3(), ui.ColorMagenta)
-	widgets = append(widgets, twitter3)
-
-	weather := NewWeatherWidget(GetWeatherLocation())
-	widgets = append(widgets, weather)
+	dashboard := NewDashboard()
 
 	//
 	// Create the layout
@@ -181,21 +285,53 @@ func main() {
 	ui.Body.X = 1
 	ui.Body.Y = 1
 
-	ui.Body.AddRows(
-		ui.NewRow(
-			ui.NewCol(6, 0, hostInfo.getGridWidget(), battery.getGridWidget(), audio.getGridWidget(), weather.getGridWidget()),
-			ui.NewCol(6, 0, cpu.getGridWidget())),
-		ui.NewRow(
-			disk.getColumn(),
-			ui.NewCol(6, 0, network.getGridWidget())),
-		ui.NewRow(
-			ui.NewCol(12, 0, repo.getGridWidget())),
-		ui.NewRow(
-			ui.NewCol(4, 0, twitter1.getGridWidget()),
-			ui.NewCol(4, 0, twitter2.getGridWidget()),
-			ui.NewCol(4, 0, twitter3.getGridWidget())))
+	ui.Body.AddRows(dashboard.buildRows()...)
 
 	ui.Body.Align()
 
-	loop(widgets, header)
+	startExporters()
+
+	loop(dashboard)
+}
+
+func main() {
+	command := parseCommandLine()
+
+	// Set up logging?
+	if LogToFile() {
+		logFile, logErr := os.OpenFile("go.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0660)
+		if logErr != nil {
+			panic(logErr)
+		}
+		defer logFile.Close()
+
+		log.SetOutput(logFile)
+	} else {
+		// Disable logging
+		log.SetFlags(0)
+		log.SetOutput(ioutil.Discard)
+	}
+
+	switch command {
+	case "":
+		if RunOnce() {
+			runOnce()
+		} else {
+			runDashboard()
+		}
+	case "agent":
+		runAgent()
+	case "attach":
+		runAttach()
+	case "hosts":
+		runHosts()
+	case "serve-ssh":
+		runSSHServer()
+	case "statusline":
+		runStatusLine()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command '%v'\n", command)
+		flag.Usage()
+		os.Exit(2)
+	}
 }
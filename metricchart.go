@@ -0,0 +1,408 @@
+package main
+
+/**
+ * Charts of any metric (or a command's output), set up in the charts config instead of each needing its own widget.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	ui "github.com/gizak/termui"
+)
+
+////////////////////////////////////////////
+// Utility: Chart Definitions
+////////////////////////////////////////////
+
+const (
+	MetricChartKindLine      = "line"
+	MetricChartKindSparkline = "sparkline"
+	MetricChartKindGauge     = "gauge"
+)
+
+// Commands become metrics under this subsystem, named after their chart
+const MetricChartCommandSubsystem = "custom"
+
+// Names end up in metric names, so nothing fancy
+var ChartNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// One entry in the charts config file
+type ChartDefinition struct {
+	Name  string `json:"name"`
+	Title string `json:"title,omitempty"`
+	// line, sparkline or gauge
+	Kind string `json:"kind"`
+	// A metric key, like "net.wlan0.rx_bytes_per_sec"
+	Metric string `json:"metric,omitempty"`
+	// Or a shell command that prints a number
+	Command string `json:"command,omitempty"`
+	// For gauges, what counts as full (100 if not set)
+	Max float64 `json:"max,omitempty"`
+	// Like "fg-green"
+	Color string `json:"color,omitempty"`
+}
+
+func (d ChartDefinition) metricKey() string {
+	if len(d.Command) > 0 {
+		return MetricChartCommandSubsystem + "." + d.Name
+	}
+
+	return d.Metric
+}
+
+// Widget names, so they don't collide with the built in ones
+func (d ChartDefinition) widgetName() string {
+	return "chart:" + d.Name
+}
+
+func (d ChartDefinition) getTitle() string {
+	if len(d.Title) > 0 {
+		return d.Title
+	}
+
+	return d.Name
+}
+
+func loadChartDefinitions(path string) ([]ChartDefinition, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var definitions []ChartDefinition
+	if err := json.Unmarshal(data, &definitions); err != nil {
+		return nil, err
+	}
+
+	valid := make([]ChartDefinition, 0, len(definitions))
+
+	for _, d := range definitions {
+		switch {
+		case !ChartNameRegexp.MatchString(d.Name):
+			log.Printf("Skipping chart '%v': names are letters, numbers and underscores", d.Name)
+		case len(d.Metric) <= 0 && len(d.Command) <= 0:
+			log.Printf("Skipping chart '%v': it needs a metric or a command", d.Name)
+		case d.Kind != MetricChartKindLine && d.Kind != MetricChartKindSparkline && d.Kind != MetricChartKindGauge:
+			log.Printf("Skipping chart '%v': unknown kind '%v'", d.Name, d.Kind)
+		default:
+			valid = append(valid, d)
+		}
+	}
+
+	return valid, nil
+}
+
+// Rows of up to three charts, to go under the rest of the dashboard
+func buildChartLayout(definitions []ChartDefinition) []LayoutRow {
+	const chartsPerRow = 3
+
+	rows := make([]LayoutRow, 0)
+
+	for start := 0; start < len(definitions); start += chartsPerRow {
+		end := start + chartsPerRow
+		if end > len(definitions) {
+			end = len(definitions)
+		}
+
+		row := make(LayoutRow, 0, end-start)
+
+		for _, d := range definitions[start:end] {
+			row = append(row, LayoutColumn{Span: 12 / (end - start), Widgets: []string{d.widgetName()}})
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// Bytes get units, percentages get a percent sign
+func formatMetricValue(key string, value float64) string {
+	switch {
+	case strings.HasSuffix(key, "_bytes_per_sec"):
+		return prettyPrintBytes(uint64(math.Max(value, 0))) + "/s"
+	case strings.HasSuffix(key, "_bytes"):
+		return prettyPrintBytes(uint64(math.Max(value, 0)))
+	case strings.HasSuffix(key, "percent"):
+		return fmt.Sprintf("%0.1f%%", value)
+	}
+
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+////////////////////////////////////////////
+// Widget: Metric Chart
+////////////////////////////////////////////
+
+// Roughly an hour and a half of updates from the rendering loop
+const MetricChartHistoryCapacity = 1024
+
+const MetricChartCommandUpdateInterval = 10 * time.Second
+
+type MetricChartWidget struct {
+	definition  ChartDefinition
+	widget      ui.GridBufferer
+	lastUpdated *time.Time
+
+	// Everything recorded for the metric since we started
+	series *TimeSeries
+	// From the command, if there is one
+	commandValue    float64
+	hasCommandValue bool
+	// Index into ChartRanges, for line charts
+	chartRange int
+}
+
+func NewMetricChartWidget(definition ChartDefinition) *MetricChartWidget {
+	color := ui.ColorCyan
+	if len(definition.Color) > 0 {
+		color = colorStringToAttribute(definition.Color)
+	}
+
+	// Create base element
+	var e ui.GridBufferer
+
+	switch definition.Kind {
+	case MetricChartKindLine:
+		c := ui.NewLineChart()
+		c.Height = 12
+		c.PaddingTop = 1
+		c.AxesColor = ui.ColorYellow
+		c.LineColor["value"] = color | ui.AttrBold
+		c.LineColor["max"] = color
+		e = c
+	case MetricChartKindSparkline:
+		// The value goes in the line's title, above the bars
+		line := ui.NewSparkline()
+		line.Height = 3
+		line.LineColor = color
+		line.TitleColor = ui.ColorWhite | ui.AttrBold
+
+		s := ui.NewSparklines(line)
+		s.Height = 6
+		e = s
+	default:
+		g := ui.NewGauge()
+		g.Height = 3
+		g.BarColor = color
+		g.LabelAlign = ui.AlignRight
+		g.PercentColor = ui.ColorWhite | ui.AttrBold
+		g.PercentColorHighlighted = g.PercentColor
+		e = g
+	}
+
+	// Create widget
+	w := &MetricChartWidget{
+		definition: definition,
+		widget:     e,
+		series:     NewTimeSeries(MetricChartHistoryCapacity),
+	}
+
+	w.loadHistory()
+	latestMetrics.addListener(w.record)
+
+	w.update()
+	w.resize()
+
+	return w
+}
+
+func (w *MetricChartWidget) getGridWidget() ui.GridBufferer {
+	return w.widget
+}
+
+// Metrics come in through record, only commands need running
+func (w *MetricChartWidget) update() {
+	if len(w.definition.Command) > 0 && shouldUpdate(w) {
+		w.runCommand()
+	}
+}
+
+func (w *MetricChartWidget) runCommand() {
+	output, exitCode, err := execAndGetOutput("sh", nil, "-c", w.definition.Command)
+
+	if err != nil || exitCode != 0 {
+		log.Printf("Error running command for chart '%v' (exit code %d): %v", w.definition.Name, exitCode, err)
+		w.hasCommandValue = false
+		return
+	}
+
+	// The first thing it prints
+	fields := strings.Fields(output)
+	if len(fields) <= 0 {
+		log.Printf("No output from command for chart '%v'", w.definition.Name)
+		w.hasCommandValue = false
+		return
+	}
+
+	value, parseErr := strconv.ParseFloat(fields[0], 64)
+	if parseErr != nil {
+		log.Printf("Failed to parse output '%v' for chart '%v': %v", fields[0], w.definition.Name, parseErr)
+		w.hasCommandValue = false
+		return
+	}
+
+	w.commandValue = value
+	w.hasCommandValue = true
+}
+
+// A MetricListener
+func (w *MetricChartWidget) record(timestamp time.Time, metrics []Metric) {
+	key := w.definition.metricKey()
+
+	for _, m := range metrics {
+		if metricKey(m) == key {
+			w.series.add(timestamp, m.Value)
+			break
+		}
+	}
+
+	w.refresh()
+}
+
+func (w *MetricChartWidget) refresh() {
+	key := w.definition.metricKey()
+
+	value := "-"
+	latest, hasValue := w.series.last()
+	if hasValue {
+		value = formatMetricValue(key, latest.Value)
+	}
+
+	switch e := w.widget.(type) {
+	case *ui.LineChart:
+		e.BorderLabel = fmt.Sprintf("%v: %v───[%v](fg-cyan)", w.definition.getTitle(), value, ChartRanges[w.chartRange].Name)
+
+		// Two points per character
+		data := buildChartData(w.series, key, w.chartRange, e.Width*2)
+
+		e.Data = map[string][]float64{"value": data.Values}
+		if data.Peaks != nil {
+			e.Data["max"] = data.Peaks
+		}
+		e.DataLabels = data.Labels
+
+	case *ui.Sparklines:
+		e.BorderLabel = w.definition.getTitle()
+		e.Lines[0].Title = value
+
+		// One point per character, scaled up since sparklines only take whole numbers.  The grid can squeeze it down to
+		// nothing inside its border while resizing.
+		width := e.Width - 2
+		if width < 0 {
+			width = 0
+		}
+
+		points := w.series.lastN(width)
+		min, max := 0.0, 0.0
+
+		for i, p := range points {
+			if i == 0 || p.Value < min {
+				min = p.Value
+			}
+
+			if i == 0 || p.Value > max {
+				max = p.Value
+			}
+		}
+
+		e.Lines[0].Data = make([]int, len(points))
+
+		for i, p := range points {
+			// Keep the lowest point off the floor, so a flat line still shows
+			if max > min {
+				e.Lines[0].Data[i] = 1 + int(999*(p.Value-min)/(max-min))
+			} else {
+				e.Lines[0].Data[i] = 1
+			}
+		}
+
+	case *ui.Gauge:
+		e.BorderLabel = w.definition.getTitle()
+
+		full := w.definition.Max
+		if full <= 0 {
+			full = 100
+		}
+
+		e.Percent = 0
+		e.Label = value
+
+		if hasValue {
+			e.Percent = int(math.Max(0, math.Min(100, 100*latest.Value/full)))
+		}
+	}
+}
+
+func (w *MetricChartWidget) handleEvent(e ui.Event) bool {
+	if e.ID != ChartRangeKey || w.definition.Kind != MetricChartKindLine {
+		return false
+	}
+
+	w.chartRange = nextChartRange(w.chartRange)
+	w.refresh()
+
+	return true
+}
+
+func (w *MetricChartWidget) resize() {
+	// Line charts and sparklines fit themselves to the width
+	w.refresh()
+}
+
+// Start with what was recorded before we started
+func (w *MetricChartWidget) loadHistory() {
+	for _, p := range queryHistory(w.definition.metricKey(), time.Now().Add(-ChartRanges[0].Duration)) {
+		w.series.add(p.Timestamp, p.Value)
+	}
+}
+
+func (w *MetricChartWidget) getMetrics() []Metric {
+	if len(w.definition.Command) <= 0 || !w.hasCommandValue {
+		return []Metric{}
+	}
+
+	help := fmt.Sprintf("Output of '%v'.", w.definition.Command)
+
+	return []Metric{newGaugeMetric(MetricChartCommandSubsystem, w.definition.Name, help, w.commandValue)}
+}
+
+func (w *MetricChartWidget) getUpdateInterval() time.Duration {
+	return MetricChartCommandUpdateInterval
+}
+
+func (w *MetricChartWidget) getLastUpdated() *time.Time {
+	return w.lastUpdated
+}
+
+func (w *MetricChartWidget) setLastUpdated(t time.Time) {
+	w.lastUpdated = &t
+}
+
+// Missing is fine, it just means no charts
+func getChartDefinitions() []ChartDefinition {
+	path := GetChartsPath()
+	if len(path) <= 0 {
+		return []ChartDefinition{}
+	}
+
+	definitions, err := loadChartDefinitions(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error loading charts from '%v': %v", path, err)
+		}
+
+		return []ChartDefinition{}
+	}
+
+	return definitions
+}
@@ -0,0 +1,255 @@
+package main
+
+/**
+ * Metrics collected by the widgets, so they can be exported somewhere other than the screen.
+ */
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+////////////////////////////////////////////
+// Utility: Metrics
+////////////////////////////////////////////
+
+type MetricType string
+
+const (
+	MetricTypeGauge   MetricType = "gauge"
+	MetricTypeCounter MetricType = "counter"
+)
+
+type MetricLabel struct {
+	Name  string
+	Value string
+}
+
+type Metric struct {
+	Subsystem string
+	Name      string
+	Help      string
+	Type      MetricType
+	Labels    []MetricLabel
+	Value     float64
+}
+
+// Widgets that collect something worth exporting implement this
+type MetricProvider interface {
+	getMetrics() []Metric
+}
+
+func newGaugeMetric(subsystem string, name string, help string, value float64, labels ...MetricLabel) Metric {
+	return Metric{
+		Subsystem: subsystem,
+		Name:      name,
+		Help:      help,
+		Type:      MetricTypeGauge,
+		Labels:    labels,
+		Value:     value,
+	}
+}
+
+func boolToMetricValue(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// Labels that describe a series rather than tell it apart, left out of its key
+var metricKeyIgnoredLabels = map[string]bool{"fstype": true}
+
+// Names one series, like "cpu.load1" or "disk./.available_bytes": the subsystem, label values, then the name
+func metricKey(m Metric) string {
+	parts := []string{m.Subsystem}
+
+	for _, label := range m.Labels {
+		if !metricKeyIgnoredLabels[label.Name] {
+			parts = append(parts, label.Value)
+		}
+	}
+
+	parts = append(parts, m.Name)
+
+	return strings.Join(parts, ".")
+}
+
+// The value of the metric's label with this name, if it has one
+func metricLabelValue(m Metric, name string) (string, bool) {
+	for _, label := range m.Labels {
+		if label.Name == name {
+			return label.Value, true
+		}
+	}
+
+	return "", false
+}
+
+func collectMetrics(widgets []CAHWidget) []Metric {
+	metrics := make([]Metric, 0)
+
+	for _, w := range widgets {
+		if provider, ok := w.(MetricProvider); ok {
+			metrics = append(metrics, provider.getMetrics()...)
+		}
+	}
+
+	return metrics
+}
+
+////////////////////////////////////////////
+// Utility: Metric Summary
+////////////////////////////////////////////
+
+// The handful of numbers that matter at a glance, pulled back out of a set of metrics
+type MetricSummary struct {
+	CPUPercent      float64
+	Load5           float64
+	Processors      int
+	HasKerberos     bool
+	KerberosValid   bool
+	HasBattery      bool
+	BatteryPercent  float64
+	BatteryCharging bool
+	// By mount point
+	DiskFreePercent map[string]float64
+	// The fullest mount, by percentage free
+	LowestDiskMount string
+	LowestDiskFree  float64
+	HasDisks        bool
+	Repos           int
+	DirtyRepos      int
+	DirtyFiles      float64
+}
+
+func summarizeMetrics(metrics []Metric) MetricSummary {
+	summary := MetricSummary{
+		DiskFreePercent: map[string]float64{},
+	}
+
+	diskSizes := map[string]float64{}
+	diskAvailable := map[string]float64{}
+	dirtyRepos := map[string]bool{}
+
+	for _, m := range metrics {
+		switch m.Subsystem + "." + m.Name {
+		case "cpu.usage_percent":
+			summary.CPUPercent = m.Value
+		case "cpu.load5":
+			summary.Load5 = m.Value
+		case "cpu.processors":
+			summary.Processors = int(m.Value)
+		case "kerberos.ticket_valid":
+			summary.HasKerberos = true
+			summary.KerberosValid = m.Value > 0
+		case "battery.percent":
+			summary.HasBattery = true
+			summary.BatteryPercent = m.Value
+		case "battery.charging":
+			summary.BatteryCharging = m.Value > 0
+		case "disk.size_bytes":
+			if mountPoint, ok := metricLabelValue(m, "mountpoint"); ok {
+				diskSizes[mountPoint] = m.Value
+			}
+		case "disk.available_bytes":
+			if mountPoint, ok := metricLabelValue(m, "mountpoint"); ok {
+				diskAvailable[mountPoint] = m.Value
+			}
+		case "git.files":
+			repo, hasRepo := metricLabelValue(m, "repo")
+			status, hasStatus := metricLabelValue(m, "status")
+			if !hasRepo || !hasStatus {
+				continue
+			}
+
+			// Ignored files don't make a repo dirty
+			if status != RepoStatusFieldDefinitions['!'].Name && m.Value > 0 {
+				dirtyRepos[repo] = true
+				summary.DirtyFiles += m.Value
+			}
+
+			if _, ok := dirtyRepos[repo]; !ok {
+				dirtyRepos[repo] = false
+			}
+		}
+	}
+
+	for mountPoint, size := range diskSizes {
+		if size <= 0 {
+			continue
+		}
+
+		freePercent := 100 * diskAvailable[mountPoint] / size
+		summary.DiskFreePercent[mountPoint] = freePercent
+
+		if !summary.HasDisks || freePercent < summary.LowestDiskFree {
+			summary.HasDisks = true
+			summary.LowestDiskMount = mountPoint
+			summary.LowestDiskFree = freePercent
+		}
+	}
+
+	summary.Repos = len(dirtyRepos)
+	for _, dirty := range dirtyRepos {
+		if dirty {
+			summary.DirtyRepos++
+		}
+	}
+
+	return summary
+}
+
+////////////////////////////////////////////
+// Utility: Latest Metrics
+////////////////////////////////////////////
+
+// Called (from the rendering loop) each time new metrics are recorded, so don't block
+type MetricListener func(timestamp time.Time, metrics []Metric)
+
+// The rendering loop records here after every update, exporters read from here (from other goroutines)
+type MetricSnapshot struct {
+	lock      sync.RWMutex
+	timestamp time.Time
+	metrics   []Metric
+	listeners []MetricListener
+}
+
+func NewMetricSnapshot() *MetricSnapshot {
+	return &MetricSnapshot{
+		metrics: make([]Metric, 0),
+	}
+}
+
+func (s *MetricSnapshot) record(widgets []CAHWidget) {
+	metrics := collectMetrics(widgets)
+
+	s.lock.Lock()
+	s.timestamp = time.Now()
+	s.metrics = metrics
+	timestamp := s.timestamp
+	listeners := s.listeners
+	s.lock.Unlock()
+
+	for _, listener := range listeners {
+		listener(timestamp, metrics)
+	}
+}
+
+func (s *MetricSnapshot) addListener(listener MetricListener) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.listeners = append(s.listeners, listener)
+}
+
+func (s *MetricSnapshot) get() (time.Time, []Metric) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.timestamp, s.metrics
+}
+
+var latestMetrics = NewMetricSnapshot()
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mqttTestPacket struct {
+	packetType byte
+	flags      byte
+	body       []byte
+}
+
+// The other half of mqttPacket
+func readMQTTTestPacket(r *bufio.Reader) (mqttTestPacket, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return mqttTestPacket{}, err
+	}
+
+	length, multiplier := 0, 1
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return mqttTestPacket{}, err
+		}
+
+		length += int(b&0x7F) * multiplier
+		multiplier *= 128
+
+		if b&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return mqttTestPacket{}, err
+	}
+
+	return mqttTestPacket{packetType: header >> 4, flags: header & 0x0F, body: body}, nil
+}
+
+func readMQTTTestString(t *testing.T, r *bytes.Reader) string {
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		t.Fatal(err)
+	}
+
+	s := make([]byte, length)
+	if _, err := io.ReadFull(r, s); err != nil {
+		t.Fatal(err)
+	}
+
+	return string(s)
+}
+
+// Accepts one client, says yes to it, and hands over everything it sends
+func startMQTTTestBroker(t *testing.T) (string, <-chan mqttTestPacket) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packets := make(chan mqttTestPacket, 16)
+
+	go func() {
+		defer listener.Close()
+		defer close(packets)
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+
+		for {
+			packet, err := readMQTTTestPacket(reader)
+			if err != nil {
+				return
+			}
+
+			if packet.packetType == mqttPacketConnect {
+				conn.Write([]byte{mqttPacketConnack << 4, 2, 0, 0})
+			}
+
+			packets <- packet
+		}
+	}()
+
+	return "tcp://" + listener.Addr().String(), packets
+}
+
+func nextMQTTTestPacket(t *testing.T, packets <-chan mqttTestPacket) mqttTestPacket {
+	select {
+	case packet, ok := <-packets:
+		if !ok {
+			t.Fatal("broker connection closed")
+		}
+		return packet
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a packet")
+	}
+
+	return mqttTestPacket{}
+}
+
+func TestMQTTRemainingLength(t *testing.T) {
+	tests := []struct {
+		length int
+		want   []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7F}},
+		{128, []byte{0x80, 0x01}},
+		{16383, []byte{0xFF, 0x7F}},
+		{16384, []byte{0x80, 0x80, 0x01}},
+	}
+
+	for _, test := range tests {
+		packet := mqttPacket(mqttPacketPublish, 0x01, make([]byte, test.length))
+
+		if packet[0] != mqttPacketPublish<<4|0x01 {
+			t.Errorf("length %d: header is %#x", test.length, packet[0])
+		}
+
+		if got := packet[1 : 1+len(test.want)]; !bytes.Equal(got, test.want) {
+			t.Errorf("length %d: remaining length is %#v, want %#v", test.length, got, test.want)
+		}
+
+		if len(packet) != 1+len(test.want)+test.length {
+			t.Errorf("length %d: packet is %d bytes", test.length, len(packet))
+		}
+	}
+}
+
+func TestMQTTClient(t *testing.T) {
+	broker, packets := startMQTTTestBroker(t)
+	broker = strings.Replace(broker, "tcp://", "tcp://user:secret@", 1)
+
+	client, err := DialMQTT(broker, "sysdash-test", &MQTTWill{Topic: "sysdash/box/status", Payload: "offline", Retain: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// CONNECT
+	connect := nextMQTTTestPacket(t, packets)
+	if connect.packetType != mqttPacketConnect || connect.flags != 0 {
+		t.Fatalf("expected CONNECT, got type %d flags %#x", connect.packetType, connect.flags)
+	}
+
+	r := bytes.NewReader(connect.body)
+	if protocol := readMQTTTestString(t, r); protocol != "MQTT" {
+		t.Errorf("protocol name is %q", protocol)
+	}
+
+	if level, _ := r.ReadByte(); level != 4 {
+		t.Errorf("protocol level is %d, want 4", level)
+	}
+
+	// Username, password, will retain, will, clean session
+	if flags, _ := r.ReadByte(); flags != 0x80|0x40|0x20|0x04|0x02 {
+		t.Errorf("connect flags are %#b", flags)
+	}
+
+	var keepAlive uint16
+	binary.Read(r, binary.BigEndian, &keepAlive)
+	if time.Duration(keepAlive)*time.Second != MQTTKeepAlive {
+		t.Errorf("keep alive is %d", keepAlive)
+	}
+
+	for _, want := range []string{"sysdash-test", "sysdash/box/status", "offline", "user", "secret"} {
+		if got := readMQTTTestString(t, r); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	if r.Len() != 0 {
+		t.Errorf("%d bytes left over in CONNECT", r.Len())
+	}
+
+	// PUBLISH, big enough to need two bytes of remaining length
+	payload := []byte(strings.Repeat("x", 300))
+	if err := client.Publish("sysdash/box/cpu", payload, true); err != nil {
+		t.Fatal(err)
+	}
+
+	publish := nextMQTTTestPacket(t, packets)
+	if publish.packetType != mqttPacketPublish || publish.flags != 0x01 {
+		t.Fatalf("expected a retained PUBLISH, got type %d flags %#x", publish.packetType, publish.flags)
+	}
+
+	r = bytes.NewReader(publish.body)
+	if topic := readMQTTTestString(t, r); topic != "sysdash/box/cpu" {
+		t.Errorf("topic is %q", topic)
+	}
+
+	if rest, _ := ioutil.ReadAll(r); !bytes.Equal(rest, payload) {
+		t.Errorf("payload is %d bytes, want %d", len(rest), len(payload))
+	}
+
+	if err := client.Publish("sysdash/box/git", []byte("{}"), false); err != nil {
+		t.Fatal(err)
+	}
+
+	if publish := nextMQTTTestPacket(t, packets); publish.flags != 0 {
+		t.Errorf("not retained, but flags are %#x", publish.flags)
+	}
+
+	// DISCONNECT
+	client.Close()
+
+	if disconnect := nextMQTTTestPacket(t, packets); disconnect.packetType != mqttPacketDisconnect || len(disconnect.body) != 0 {
+		t.Errorf("expected DISCONNECT, got type %d", disconnect.packetType)
+	}
+}
+
+func TestMQTTClientWithoutWill(t *testing.T) {
+	broker, packets := startMQTTTestBroker(t)
+
+	client, err := DialMQTT(broker, "sysdash-test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	connect := nextMQTTTestPacket(t, packets)
+
+	// Clean session, and nothing else
+	if flags := connect.body[7]; flags != 0x02 {
+		t.Errorf("connect flags are %#b", flags)
+	}
+}
+
+func TestBuildMQTTStates(t *testing.T) {
+	metrics := []Metric{
+		newGaugeMetric("cpu", "load1", "", 1.5),
+		newGaugeMetric("cpu", "core_usage_percent", "", 20, MetricLabel{Name: "core", Value: "0"}),
+		newGaugeMetric("disk", "available_bytes", "", 25, MetricLabel{Name: "mountpoint", Value: "/mnt/it's"}),
+		newGaugeMetric("disk", "size_bytes", "", 100, MetricLabel{Name: "mountpoint", Value: "/mnt/it's"}),
+		newGaugeMetric("disk", "available_bytes", "", 1, MetricLabel{Name: "mountpoint", Value: "timestamp"}),
+		newGaugeMetric("disk", "size_bytes", "", 2, MetricLabel{Name: "mountpoint", Value: "timestamp"}),
+	}
+
+	states := buildMQTTStates(metrics)
+
+	if states["cpu"]["load1"] != 1.5 {
+		t.Errorf("load1 is %v", states["cpu"]["load1"])
+	}
+
+	if cores, _ := states["cpu"]["core_usage_percent"].(map[string]float64); cores["0"] != 20 {
+		t.Errorf("core usage is %v", states["cpu"]["core_usage_percent"])
+	}
+
+	mounts := states["disk"]["mounts"].(map[string]interface{})
+	if len(states["disk"]) != 1 || len(mounts) != 2 {
+		t.Fatalf("disk state is %v", states["disk"])
+	}
+
+	if free := mounts["/mnt/it's"].(map[string]interface{})["free_percent"]; free != 25.0 {
+		t.Errorf("free percent is %v", free)
+	}
+
+	// A mount point can be called anything without getting mixed up with the timestamp
+	states["disk"]["timestamp"] = 1
+	payload, _ := json.Marshal(states["disk"])
+
+	var decoded struct {
+		Timestamp int
+		Mounts    map[string]map[string]float64
+	}
+
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Timestamp != 1 || decoded.Mounts["timestamp"]["size_bytes"] != 2 {
+		t.Errorf("decoded %+v", decoded)
+	}
+}
+
+func TestBuildMQTTStatesByLabelName(t *testing.T) {
+	metrics := []Metric{
+		// Labels in any order
+		newGaugeMetric("disk", "size_bytes", "", 100, MetricLabel{Name: "fstype", Value: "ext4"},
+			MetricLabel{Name: "mountpoint", Value: "/"}),
+		newGaugeMetric("cpu", "time_percent", "", 35, MetricLabel{Name: "host", Value: "web1"},
+			MetricLabel{Name: "mode", Value: "iowait"}),
+		// Without the labels they're filed under
+		newGaugeMetric("disk", "size_bytes", "", 50),
+		newGaugeMetric("disk", "available_bytes", "", 5, MetricLabel{Name: "fstype", Value: "tmpfs"}),
+		newGaugeMetric("cpu", "core_usage_percent", "", 20, MetricLabel{Name: "host", Value: "web1"}),
+	}
+
+	states := buildMQTTStates(metrics)
+
+	mounts := states["disk"]["mounts"].(map[string]interface{})
+	if len(mounts) != 1 || mounts["/"].(map[string]interface{})["size_bytes"] != 100.0 {
+		t.Errorf("mounts are %v", mounts)
+	}
+
+	if modes, _ := states["cpu"]["time_percent"].(map[string]float64); len(modes) != 1 || modes["iowait"] != 35 {
+		t.Errorf("time percent is %v", states["cpu"]["time_percent"])
+	}
+
+	if _, ok := states["cpu"]["core_usage_percent"]; ok {
+		t.Errorf("core usage without a core is %v", states["cpu"]["core_usage_percent"])
+	}
+}
+
+func TestHomeAssistantDiskTemplates(t *testing.T) {
+	metrics := []Metric{
+		newGaugeMetric("disk", "size_bytes", "", 100, MetricLabel{Name: "mountpoint", Value: "/"}),
+		newGaugeMetric("disk", "size_bytes", "", 100, MetricLabel{Name: "mountpoint", Value: `/mnt/it's "here"\now`}),
+	}
+
+	templates := map[string]string{}
+	for _, sensor := range homeAssistantSensors(buildMQTTStates(metrics)) {
+		if sensor.Widget == "disk" {
+			templates[sensor.ID] = sensor.Template
+		}
+	}
+
+	want := map[string]string{
+		"disk_free_":                  `{{ value_json.mounts["/"].free_percent | round(1) }}`,
+		"disk_free_mnt_it_s_here_now": `{{ value_json.mounts["/mnt/it's \"here\"\\now"].free_percent | round(1) }}`,
+	}
+
+	for id, template := range want {
+		if templates[id] != template {
+			t.Errorf("%v: got %v, want %v", id, templates[id], template)
+		}
+	}
+
+	if len(templates) != len(want) {
+		t.Errorf("got %v", templates)
+	}
+}
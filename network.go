@@ -8,7 +8,10 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"sort"
+	"time"
 
+	linuxproc "github.com/c9s/goprocinfo/linux"
 	ui "github.com/gizak/termui"
 )
 
@@ -18,6 +21,10 @@ import (
 
 type NetworkWidget struct {
 	widget *ui.List
+
+	// Byte counters by interface, just enough to get a rate from
+	rxBytes map[string]*TimeSeries
+	txBytes map[string]*TimeSeries
 }
 
 func NewNetworkWidget() *NetworkWidget {
@@ -29,7 +36,9 @@ func NewNetworkWidget() *NetworkWidget {
 
 	// Create widget
 	w := &NetworkWidget{
-		widget: e,
+		widget:  e,
+		rxBytes: map[string]*TimeSeries{},
+		txBytes: map[string]*TimeSeries{},
 	}
 
 	w.update()
@@ -82,8 +91,73 @@ func (w *NetworkWidget) update() {
 
 		// TODO: Add WLAN Addresses, Network Location (geoip?)
 	}
+
+	w.loadTraffic()
+}
+
+func (w *NetworkWidget) loadTraffic() {
+	stats, err := linuxproc.ReadNetworkStat("/proc/net/dev")
+
+	if err != nil {
+		log.Printf("Error loading network traffic: %v", err)
+		return
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool, len(stats))
+
+	for _, stat := range stats {
+		if stat.Iface == "lo" {
+			continue
+		}
+
+		seen[stat.Iface] = true
+
+		if _, ok := w.rxBytes[stat.Iface]; !ok {
+			w.rxBytes[stat.Iface] = NewTimeSeries(2)
+			w.txBytes[stat.Iface] = NewTimeSeries(2)
+		}
+
+		w.rxBytes[stat.Iface].add(now, float64(stat.RxBytes))
+		w.txBytes[stat.Iface].add(now, float64(stat.TxBytes))
+	}
+
+	// Interfaces come and go (VPNs, docker)
+	for iface := range w.rxBytes {
+		if !seen[iface] {
+			delete(w.rxBytes, iface)
+			delete(w.txBytes, iface)
+		}
+	}
 }
 
 func (w *NetworkWidget) resize() {
 	// Do nothing
 }
+
+func (w *NetworkWidget) getMetrics() []Metric {
+	ifaces := make([]string, 0, len(w.rxBytes))
+
+	for iface := range w.rxBytes {
+		ifaces = append(ifaces, iface)
+	}
+
+	sort.Strings(ifaces)
+
+	metrics := make([]Metric, 0)
+
+	for _, iface := range ifaces {
+		label := MetricLabel{Name: "interface", Value: iface}
+
+		// Nothing until there are two readings
+		if rate, ok := w.rxBytes[iface].rate(); ok {
+			metrics = append(metrics, newGaugeMetric("net", "rx_bytes_per_sec", "Bytes received per second.", rate, label))
+		}
+
+		if rate, ok := w.txBytes[iface].rate(); ok {
+			metrics = append(metrics, newGaugeMetric("net", "tx_bytes_per_sec", "Bytes sent per second.", rate, label))
+		}
+	}
+
+	return metrics
+}
@@ -0,0 +1,387 @@
+package main
+
+/**
+ * The processes using the most CPU (or memory), like a small top.
+ */
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	linuxproc "github.com/c9s/goprocinfo/linux"
+	ui "github.com/gizak/termui"
+)
+
+////////////////////////////////////////////
+// Utility: Process Sampling
+////////////////////////////////////////////
+
+const ProcPath = "/proc"
+
+// What ps calls them
+var ProcessStateNames = map[string]string{
+	"R": "running",
+	"S": "sleeping",
+	"D": "waiting on disk",
+	"Z": "zombie",
+	"T": "stopped",
+	"t": "stopped by a debugger",
+	"I": "idle",
+	"X": "dead",
+}
+
+type ProcessInfo struct {
+	PID     uint64
+	User    string
+	Command string
+	State   string
+	Threads int64
+	// 100 is one whole core, like top
+	CPUPercent float64
+	RSSBytes   uint64
+}
+
+// /proc/[pid]/stat counts CPU time since the process started, so it takes two readings to say how busy it is now
+type ProcessSampler struct {
+	previousTicks map[uint64]uint64
+	previousTotal uint64
+	// By uid, looking them up every time is slow
+	usernames map[uint32]string
+}
+
+func NewProcessSampler() *ProcessSampler {
+	return &ProcessSampler{
+		previousTicks: map[uint64]uint64{},
+		usernames:     map[uint32]string{},
+	}
+}
+
+func (s *ProcessSampler) getUsername(uid uint32) string {
+	if name, ok := s.usernames[uid]; ok {
+		return name
+	}
+
+	name := strconv.FormatUint(uint64(uid), 10)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+
+	s.usernames[uid] = name
+
+	return name
+}
+
+// Everything running now.  The first time there's nothing to compare to, so it's all 0% CPU.
+func (s *ProcessSampler) sample() ([]ProcessInfo, error) {
+	stats, err := linuxproc.ReadStat(filepath.Join(ProcPath, "stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(ProcPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Jiffies that went by on one core
+	total := cpuStatTotalJiffies(stats.CPUStatAll)
+	elapsed := 0.0
+	if s.previousTotal > 0 && len(stats.CPUStats) > 0 {
+		elapsed = float64(total-s.previousTotal) / float64(len(stats.CPUStats))
+	}
+
+	pageSize := uint64(os.Getpagesize())
+	ticks := make(map[uint64]uint64, len(entries))
+	processes := make([]ProcessInfo, 0, len(entries))
+
+	for _, entry := range entries {
+		pid, err := strconv.ParseUint(entry.Name(), 10, 64)
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+
+		dir := filepath.Join(ProcPath, entry.Name())
+
+		// Processes come and go while we read, so missing ones are fine
+		stat, err := linuxproc.ReadProcessStat(filepath.Join(dir, "stat"))
+		if err != nil {
+			continue
+		}
+
+		info := ProcessInfo{
+			PID:      pid,
+			Command:  "[" + stat.Comm + "]",
+			State:    stat.State,
+			Threads:  stat.NumThreads,
+			RSSBytes: uint64(stat.Rss) * pageSize,
+		}
+
+		// Kernel threads don't have a command line.  Arguments can have newlines in them, which a table row can't.
+		if cmdline, err := linuxproc.ReadProcessCmdline(filepath.Join(dir, "cmdline")); err == nil && len(cmdline) > 0 {
+			info.Command = strings.Join(strings.Fields(cmdline), " ")
+		}
+
+		if sys, ok := entry.Sys().(*syscall.Stat_t); ok {
+			info.User = s.getUsername(sys.Uid)
+		}
+
+		ticks[pid] = stat.Utime + stat.Stime
+
+		if previous, ok := s.previousTicks[pid]; ok && elapsed > 0 && ticks[pid] >= previous {
+			info.CPUPercent = 100 * float64(ticks[pid]-previous) / elapsed
+		}
+
+		processes = append(processes, info)
+	}
+
+	// Only keeps the ones still around
+	s.previousTicks = ticks
+	s.previousTotal = total
+
+	return processes, nil
+}
+
+////////////////////////////////////////////
+// Utility: Process Sorting
+////////////////////////////////////////////
+
+// Switches what the processes are sorted by, when they have the focus
+const ProcessSortKey = "o"
+
+type ProcessSort int
+
+const (
+	ProcessSortCPU ProcessSort = iota
+	ProcessSortMemory
+)
+
+var ProcessSortNames = []string{"CPU", "memory"}
+
+func (s ProcessSort) String() string {
+	return ProcessSortNames[s]
+}
+
+func (s ProcessSort) next() ProcessSort {
+	return (s + 1) % ProcessSort(len(ProcessSortNames))
+}
+
+// Biggest first, the other one breaking ties (lots of processes are at 0% CPU)
+func sortProcesses(processes []ProcessInfo, by ProcessSort) {
+	sort.SliceStable(processes, func(i, j int) bool {
+		a, b := processes[i], processes[j]
+
+		if by == ProcessSortMemory {
+			if a.RSSBytes != b.RSSBytes {
+				return a.RSSBytes > b.RSSBytes
+			}
+
+			return a.CPUPercent > b.CPUPercent
+		}
+
+		if a.CPUPercent != b.CPUPercent {
+			return a.CPUPercent > b.CPUPercent
+		}
+
+		return a.RSSBytes > b.RSSBytes
+	})
+}
+
+////////////////////////////////////////////
+// Widget: Processes
+////////////////////////////////////////////
+
+const ProcessWidgetUpdateInterval = 5 * time.Second
+
+const (
+	ProcessWidgetMaxRows        = 10
+	ProcessWidgetCompactMaxRows = 5
+)
+
+// Everything but the command, which gets whatever's left
+const (
+	ProcessUserWidth  = 8
+	ProcessOtherWidth = len("1234567 ") + ProcessUserWidth + len(" 100.0% 1023.99M S ")
+)
+
+type ProcessWidget struct {
+	widget      *ui.Table
+	sampler     *ProcessSampler
+	lastUpdated *time.Time
+	compact     bool
+
+	sortBy ProcessSort
+	// All of them, sorted
+	processes []ProcessInfo
+	// What's in the table, after the header
+	shown []ProcessInfo
+}
+
+func NewProcessWidget() *ProcessWidget {
+	// Create base element
+	e := ui.NewTable()
+	e.Border = true
+	e.Separator = false
+
+	// Create widget
+	w := &ProcessWidget{
+		widget:  e,
+		sampler: NewProcessSampler(),
+	}
+
+	w.update()
+	w.resize()
+
+	return w
+}
+
+func (w *ProcessWidget) getGridWidget() ui.GridBufferer {
+	return w.widget
+}
+
+func (w *ProcessWidget) update() {
+	if !shouldUpdate(w) {
+		return
+	}
+
+	processes, err := w.sampler.sample()
+	if err != nil {
+		log.Printf("Error reading processes: %v", err)
+		return
+	}
+
+	w.processes = processes
+	w.refreshRows()
+}
+
+func (w *ProcessWidget) refreshRows() {
+	sortProcesses(w.processes, w.sortBy)
+
+	maxRows := ProcessWidgetMaxRows
+	if w.compact {
+		maxRows = ProcessWidgetCompactMaxRows
+	}
+
+	w.shown = w.processes
+	if len(w.shown) > maxRows {
+		w.shown = w.shown[:maxRows]
+	}
+
+	commandWidth := w.widget.Width - 2 - ProcessOtherWidth
+	if commandWidth < 10 {
+		commandWidth = 10
+	}
+
+	// The sorted column gets an arrow
+	cpuHeader, rssHeader := "CPU%", "RSS"
+	if w.sortBy == ProcessSortMemory {
+		rssHeader += "▼"
+	} else {
+		cpuHeader += "▼"
+	}
+
+	rows := [][]string{{"[PID](fg-white,fg-bold)", "[User](fg-white,fg-bold)", "[Command](fg-white,fg-bold)",
+		fmt.Sprintf("[%v](fg-white,fg-bold)", cpuHeader), fmt.Sprintf("[%v](fg-white,fg-bold)", rssHeader),
+		"[S](fg-white,fg-bold)"}}
+
+	for _, p := range w.shown {
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", p.PID),
+			truncateToWidth(escapeMarkup(p.User), ProcessUserWidth),
+			truncateToWidth(escapeMarkup(p.Command), commandWidth),
+			fmt.Sprintf("[%5.1f%%](%s)", p.CPUPercent, percentToAttributeString(int(p.CPUPercent), 0, 100, true)),
+			prettyPrintBytes(p.RSSBytes),
+			p.State,
+		})
+	}
+
+	w.widget.Rows = rows
+	w.widget.Height = len(rows) + 2
+	w.widget.BorderLabel = fmt.Sprintf("Processes ── by %v (%v to switch)", w.sortBy, ProcessSortKey)
+}
+
+func (w *ProcessWidget) resize() {
+	// The command gets whatever width is left
+	w.refreshRows()
+}
+
+func (w *ProcessWidget) setCompact(compact bool) {
+	w.compact = compact
+	w.refreshRows()
+}
+
+// Only while it has the focus, so the key's free for everything else the rest of the time
+func (w *ProcessWidget) handleFocusedEvent(e ui.Event) bool {
+	if e.ID != ProcessSortKey {
+		return false
+	}
+
+	w.sortBy = w.sortBy.next()
+	w.refreshRows()
+
+	return true
+}
+
+// The whole command line of the process under the pointer, and the rest of what there wasn't room for
+func (w *ProcessWidget) getDetailAt(x int, y int) (string, []string, bool) {
+	b := &w.widget.Block
+
+	// Inside the border, after the header
+	row := y - b.Y - 2
+	if x < b.X || x >= b.X+b.Width || row < 0 || row >= len(w.shown) {
+		return "", nil, false
+	}
+
+	p := w.shown[row]
+
+	state := p.State
+	if name, ok := ProcessStateNames[p.State]; ok {
+		state = fmt.Sprintf("%v (%v)", p.State, name)
+	}
+
+	lines := []string{
+		fmt.Sprintf("Command: %v", escapeMarkup(p.Command)),
+		fmt.Sprintf("User:    %v", escapeMarkup(p.User)),
+		fmt.Sprintf("State:   %v", state),
+		fmt.Sprintf("Threads: %d", p.Threads),
+		fmt.Sprintf("CPU:     %0.1f%%", p.CPUPercent),
+		fmt.Sprintf("RSS:     %v", prettyPrintBytes(p.RSSBytes)),
+	}
+
+	return fmt.Sprintf("Process: %d", p.PID), lines, true
+}
+
+func (w *ProcessWidget) getMetrics() []Metric {
+	running := 0
+
+	for _, p := range w.processes {
+		if p.State == "R" {
+			running++
+		}
+	}
+
+	return []Metric{
+		newGaugeMetric("processes", "count", "Number of processes.", float64(len(w.processes))),
+		newGaugeMetric("processes", "running", "Number of processes running (or ready to).", float64(running)),
+	}
+}
+
+func (w *ProcessWidget) getUpdateInterval() time.Duration {
+	return ProcessWidgetUpdateInterval
+}
+
+func (w *ProcessWidget) getLastUpdated() *time.Time {
+	return w.lastUpdated
+}
+
+func (w *ProcessWidget) setLastUpdated(t time.Time) {
+	w.lastUpdated = &t
+}
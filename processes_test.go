@@ -0,0 +1,141 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	ui "github.com/gizak/termui"
+)
+
+func processPIDs(processes []ProcessInfo) []uint64 {
+	pids := make([]uint64, len(processes))
+
+	for i, p := range processes {
+		pids[i] = p.PID
+	}
+
+	return pids
+}
+
+func TestSortProcesses(t *testing.T) {
+	processes := []ProcessInfo{
+		{PID: 1, CPUPercent: 0, RSSBytes: 100},
+		{PID: 2, CPUPercent: 50, RSSBytes: 10},
+		{PID: 3, CPUPercent: 0, RSSBytes: 300},
+		{PID: 4, CPUPercent: 150, RSSBytes: 10},
+		{PID: 5, CPUPercent: 50, RSSBytes: 20},
+	}
+
+	tests := []struct {
+		by   ProcessSort
+		want []uint64
+	}{
+		// Ties go to the other one
+		{ProcessSortCPU, []uint64{4, 5, 2, 3, 1}},
+		{ProcessSortMemory, []uint64{3, 1, 5, 4, 2}},
+	}
+
+	for _, test := range tests {
+		sorted := append([]ProcessInfo{}, processes...)
+		sortProcesses(sorted, test.by)
+
+		if got := processPIDs(sorted); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("by %v: got %v, want %v", test.by, got, test.want)
+		}
+	}
+
+	if ProcessSortMemory.next() != ProcessSortCPU {
+		t.Errorf("memory is followed by %v", ProcessSortMemory.next())
+	}
+}
+
+func newProcessTestWidget() *ProcessWidget {
+	w := &ProcessWidget{widget: ui.NewTable()}
+	w.widget.Width = 80
+
+	for pid := uint64(1); pid <= 12; pid++ {
+		w.processes = append(w.processes, ProcessInfo{PID: pid, User: "root", Command: "sleep 1", State: "S",
+			CPUPercent: float64(pid), RSSBytes: 1024 * (20 - pid)})
+	}
+
+	// A kernel thread and a command that looks like markup
+	w.processes[0].Command = "[kworker/0:1-events]"
+	w.processes[0].CPUPercent = 99
+	w.processes[1].Command = "echo [x](fg-red)"
+	w.processes[1].CPUPercent = 98
+
+	return w
+}
+
+func TestProcessWidgetRefreshRows(t *testing.T) {
+	w := newProcessTestWidget()
+	w.refreshRows()
+
+	rows := w.widget.Rows
+	if len(rows) != 1+ProcessWidgetMaxRows || w.widget.Height != len(rows)+2 {
+		t.Fatalf("%d rows, %d high", len(rows), w.widget.Height)
+	}
+
+	if rows[0][3] != "[CPU%▼](fg-white,fg-bold)" || rows[0][4] != "[RSS](fg-white,fg-bold)" {
+		t.Errorf("header is %q", rows[0])
+	}
+
+	want := []string{"1", "root", "[kworker/0:1-events]",
+		"[ 99.0%](" + percentToAttributeString(99, 0, 100, true) + ")", "19.00K", "S"}
+	if !reflect.DeepEqual(rows[1], want) {
+		t.Errorf("kernel thread row is %q, want %q", rows[1], want)
+	}
+
+	if rows[2][2] != "echo ⁅x](fg-red)" {
+		t.Errorf("markup lookalike command is %q", rows[2][2])
+	}
+
+	if got := processPIDs(w.shown); !reflect.DeepEqual(got, []uint64{1, 2, 12, 11, 10, 9, 8, 7, 6, 5}) {
+		t.Errorf("shown %v", got)
+	}
+
+	// Switching the sort
+	if !w.handleFocusedEvent(ui.Event{ID: ProcessSortKey}) || w.sortBy != ProcessSortMemory {
+		t.Fatalf("sort key didn't switch to memory")
+	}
+
+	if got := processPIDs(w.shown); !reflect.DeepEqual(got, []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}) {
+		t.Errorf("by memory shown %v", got)
+	}
+
+	if w.widget.Rows[0][4] != "[RSS▼](fg-white,fg-bold)" {
+		t.Errorf("header by memory is %q", w.widget.Rows[0])
+	}
+
+	w.setCompact(true)
+	if len(w.shown) != ProcessWidgetCompactMaxRows {
+		t.Errorf("compact shows %d", len(w.shown))
+	}
+
+	// Narrow tables still get some of the command
+	w.widget.Width = 20
+	w.refreshRows()
+
+	if got := w.widget.Rows[1][2]; got != "[kworker/…" {
+		t.Errorf("narrow command is %q", got)
+	}
+}
+
+func TestProcessWidgetDetail(t *testing.T) {
+	w := newProcessTestWidget()
+	w.refreshRows()
+
+	title, lines, ok := w.getDetailAt(5, 2)
+	if !ok || title != "Process: 1" || lines[0] != "Command: [kworker/0:1-events]" {
+		t.Errorf("got %v %q %v", title, lines, ok)
+	}
+
+	// The header and the border aren't processes
+	if _, _, ok := w.getDetailAt(5, 1); ok {
+		t.Errorf("detail for the header")
+	}
+
+	if _, _, ok := w.getDetailAt(80, 2); ok {
+		t.Errorf("detail past the right side")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+/**
+ * Serve the collected metrics in the Prometheus text exposition format.
+ */
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+////////////////////////////////////////////
+// Utility: Prometheus Exporter
+////////////////////////////////////////////
+
+const PrometheusMetricPrefix = "sysdash"
+
+func prometheusMetricName(m Metric) string {
+	return fmt.Sprintf("%s_%s_%s", PrometheusMetricPrefix, m.Subsystem, m.Name)
+}
+
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// HELP text gets the same, minus the quotes
+var prometheusHelpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func prometheusLabels(labels []MetricLabel) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	parts := make([]string, len(labels))
+
+	for i, l := range labels {
+		parts[i] = fmt.Sprintf("%s=\"%s\"", l.Name, prometheusLabelEscaper.Replace(l.Value))
+	}
+
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatPrometheusMetrics(metrics []Metric) string {
+	var out bytes.Buffer
+
+	// Group samples by name, HELP/TYPE have to show up once per family
+	families := make([]string, 0)
+	samples := make(map[string][]Metric, 0)
+
+	for _, m := range metrics {
+		name := prometheusMetricName(m)
+
+		if _, ok := samples[name]; !ok {
+			families = append(families, name)
+		}
+
+		samples[name] = append(samples[name], m)
+	}
+
+	for _, name := range families {
+		first := samples[name][0]
+
+		fmt.Fprintf(&out, "# HELP %s %s\n", name, prometheusHelpEscaper.Replace(first.Help))
+		fmt.Fprintf(&out, "# TYPE %s %s\n", name, first.Type)
+
+		for _, m := range samples[name] {
+			fmt.Fprintf(&out, "%s%s %s\n", name, prometheusLabels(m.Labels), strconv.FormatFloat(m.Value, 'g', -1, 64))
+		}
+	}
+
+	return out.String()
+}
+
+func handlePrometheusMetrics(rw http.ResponseWriter, req *http.Request) {
+	_, metrics := latestMetrics.get()
+
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(rw, formatPrometheusMetrics(metrics))
+}
+
+func startPrometheusExporter(address string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", handlePrometheusMetrics)
+
+	go func() {
+		err := http.ListenAndServe(address, mux)
+
+		if err != nil {
+			log.Printf("Error serving metrics on '%v': %v", address, err)
+		}
+	}()
+}
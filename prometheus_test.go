@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFormatPrometheusMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics []Metric
+		want    string
+	}{
+		{"nothing", nil, ""},
+		{"unlabeled gauge", []Metric{newGaugeMetric("cpu", "load1", "1 minute load average.", 1.5)},
+			"# HELP sysdash_cpu_load1 1 minute load average.\n" +
+				"# TYPE sysdash_cpu_load1 gauge\n" +
+				"sysdash_cpu_load1 1.5\n"},
+		{"counter", []Metric{{Subsystem: "net", Name: "received_bytes_total", Help: "Bytes received.",
+			Type: MetricTypeCounter, Value: 123456789}},
+			"# HELP sysdash_net_received_bytes_total Bytes received.\n" +
+				"# TYPE sysdash_net_received_bytes_total counter\n" +
+				"sysdash_net_received_bytes_total 1.23456789e+08\n"},
+		{"labeled gauges grouped by name", []Metric{
+			newGaugeMetric("disk", "available_bytes", "Free space.", 10, MetricLabel{Name: "mount", Value: "/"}),
+			newGaugeMetric("cpu", "load1", "1 minute load average.", 0.5),
+			newGaugeMetric("disk", "available_bytes", "Free space.", 20, MetricLabel{Name: "mount", Value: "/home"},
+				MetricLabel{Name: "fstype", Value: "ext4"}),
+		},
+			"# HELP sysdash_disk_available_bytes Free space.\n" +
+				"# TYPE sysdash_disk_available_bytes gauge\n" +
+				"sysdash_disk_available_bytes{mount=\"/\"} 10\n" +
+				"sysdash_disk_available_bytes{mount=\"/home\",fstype=\"ext4\"} 20\n" +
+				"# HELP sysdash_cpu_load1 1 minute load average.\n" +
+				"# TYPE sysdash_cpu_load1 gauge\n" +
+				"sysdash_cpu_load1 0.5\n"},
+		{"escaping", []Metric{newGaugeMetric("git", "dirty", "Dirty,\nor \\ not.", 1,
+			MetricLabel{Name: "repo", Value: "say \"hi\"\nC:\\src"})},
+			"# HELP sysdash_git_dirty Dirty,\\nor \\\\ not.\n" +
+				"# TYPE sysdash_git_dirty gauge\n" +
+				"sysdash_git_dirty{repo=\"say \\\"hi\\\"\\nC:\\\\src\"} 1\n"},
+	}
+
+	for _, test := range tests {
+		if got := formatPrometheusMetrics(test.metrics); got != test.want {
+			t.Errorf("%v: got\n%v\nwant\n%v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+/**
+ * Push the collected metrics to StatsD, Graphite or InfluxDB after every update.
+ */
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+////////////////////////////////////////////
+// Utility: Push Formats
+////////////////////////////////////////////
+
+const (
+	PushFormatStatsD   = "statsd"
+	PushFormatGraphite = "graphite"
+	PushFormatInflux   = "influx"
+)
+
+// Used when the target doesn't say udp:// or tcp://
+var defaultPushNetworks = map[string]string{
+	PushFormatStatsD:   "udp",
+	PushFormatGraphite: "tcp",
+	PushFormatInflux:   "udp",
+}
+
+// Keep UDP datagrams under a typical MTU
+const MaxPushDatagramSize = 1400
+
+func formatPushValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+var STATSD_UNSAFE_REGEXP = regexp.MustCompile(`[:|@#,\s]`)
+
+// DogStatsD style tags (plain StatsD servers ignore everything after the type)
+func formatStatsDLine(prefix string, m Metric, tags []MetricLabel) string {
+	name := STATSD_UNSAFE_REGEXP.ReplaceAllLiteralString(fmt.Sprintf("%s.%s.%s", prefix, m.Subsystem, m.Name), "_")
+	allTags := append(append([]MetricLabel{}, tags...), m.Labels...)
+
+	line := fmt.Sprintf("%s:%s|g", name, formatPushValue(m.Value))
+
+	if len(allTags) > 0 {
+		parts := make([]string, len(allTags))
+
+		for i, t := range allTags {
+			parts[i] = STATSD_UNSAFE_REGEXP.ReplaceAllLiteralString(t.Name, "_") + ":" + STATSD_UNSAFE_REGEXP.ReplaceAllLiteralString(t.Value, "_")
+		}
+
+		line += "|#" + strings.Join(parts, ",")
+	}
+
+	return line
+}
+
+var GRAPHITE_UNSAFE_REGEXP = regexp.MustCompile(`[;!^=~\s]`)
+
+// Graphite 1.1+ tagged series
+func formatGraphiteLine(prefix string, m Metric, tags []MetricLabel, timestamp time.Time) string {
+	name := GRAPHITE_UNSAFE_REGEXP.ReplaceAllLiteralString(fmt.Sprintf("%s.%s.%s", prefix, m.Subsystem, m.Name), "_")
+	allTags := append(append([]MetricLabel{}, tags...), m.Labels...)
+
+	for _, t := range allTags {
+		value := GRAPHITE_UNSAFE_REGEXP.ReplaceAllLiteralString(t.Value, "_")
+		if len(value) == 0 {
+			value = "_"
+		}
+
+		name += ";" + GRAPHITE_UNSAFE_REGEXP.ReplaceAllLiteralString(t.Name, "_") + "=" + value
+	}
+
+	return fmt.Sprintf("%s %s %d", name, formatPushValue(m.Value), timestamp.Unix())
+}
+
+var influxKeyEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+var influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+
+func formatInfluxLine(prefix string, m Metric, tags []MetricLabel, timestamp time.Time) string {
+	measurement := influxMeasurementEscaper.Replace(fmt.Sprintf("%s_%s", prefix, m.Subsystem))
+	allTags := append(append([]MetricLabel{}, tags...), m.Labels...)
+
+	// Influx wants tags sorted by key
+	sort.SliceStable(allTags, func(i, j int) bool { return allTags[i].Name < allTags[j].Name })
+
+	for _, t := range allTags {
+		if len(t.Value) == 0 {
+			// Empty tag values aren't allowed
+			continue
+		}
+
+		measurement += "," + influxKeyEscaper.Replace(t.Name) + "=" + influxKeyEscaper.Replace(t.Value)
+	}
+
+	return fmt.Sprintf("%s %s=%s %d", measurement, influxKeyEscaper.Replace(m.Name), formatPushValue(m.Value), timestamp.UnixNano())
+}
+
+func formatPushLines(format string, prefix string, tags []MetricLabel, timestamp time.Time, metrics []Metric) []string {
+	lines := make([]string, 0, len(metrics))
+
+	for _, m := range metrics {
+		switch format {
+		case PushFormatStatsD:
+			lines = append(lines, formatStatsDLine(prefix, m, tags))
+		case PushFormatGraphite:
+			lines = append(lines, formatGraphiteLine(prefix, m, tags, timestamp))
+		case PushFormatInflux:
+			lines = append(lines, formatInfluxLine(prefix, m, tags, timestamp))
+		}
+	}
+
+	return lines
+}
+
+////////////////////////////////////////////
+// Utility: Metric Pusher
+////////////////////////////////////////////
+
+type metricBatch struct {
+	timestamp time.Time
+	metrics   []Metric
+}
+
+type MetricPusher struct {
+	format  string
+	network string
+	address string
+	prefix  string
+	tags    []MetricLabel
+	conn    net.Conn
+	batches chan metricBatch
+}
+
+/**
+ * Build a pusher.
+ *
+ * target:  Where to send things, like "udp://localhost:8125" or "graphite.example.com:2003".
+ * format:  One of statsd, graphite or influx.
+ * prefix:  Prepended to every metric name.
+ * tags:    Sent along with every metric.
+ */
+func NewMetricPusher(target string, format string, prefix string, tags []MetricLabel) (*MetricPusher, error) {
+	network, ok := defaultPushNetworks[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown push format '%v'", format)
+	}
+
+	address := target
+
+	if strings.Contains(target, "://") {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, fmt.Errorf("bad push target '%v': %v", target, err)
+		}
+
+		if u.Scheme != "udp" && u.Scheme != "tcp" {
+			return nil, fmt.Errorf("unknown push network '%v'", u.Scheme)
+		}
+
+		network = u.Scheme
+		address = u.Host
+	}
+
+	p := &MetricPusher{
+		format:  format,
+		network: network,
+		address: address,
+		prefix:  prefix,
+		tags:    tags,
+		batches: make(chan metricBatch, 1),
+	}
+
+	go p.run()
+
+	return p, nil
+}
+
+// Queue up a batch, dropping it if the last one still hasn't gone out
+func (p *MetricPusher) push(timestamp time.Time, metrics []Metric) {
+	select {
+	case p.batches <- metricBatch{timestamp: timestamp, metrics: metrics}:
+	default:
+		log.Printf("Dropping metrics for %v, still busy with the last batch", p.address)
+	}
+}
+
+func (p *MetricPusher) run() {
+	for batch := range p.batches {
+		lines := formatPushLines(p.format, p.prefix, p.tags, batch.timestamp, batch.metrics)
+
+		err := p.send(lines)
+
+		if err != nil {
+			log.Printf("Error pushing metrics to %v://%v: %v", p.network, p.address, err)
+
+			// Reconnect next time
+			if p.conn != nil {
+				p.conn.Close()
+				p.conn = nil
+			}
+		}
+	}
+}
+
+func (p *MetricPusher) send(lines []string) error {
+	if p.conn == nil {
+		conn, err := net.DialTimeout(p.network, p.address, 5*time.Second)
+		if err != nil {
+			return err
+		}
+
+		p.conn = conn
+	}
+
+	p.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+
+	if p.network == "tcp" {
+		_, err := p.conn.Write([]byte(strings.Join(lines, "\n") + "\n"))
+		return err
+	}
+
+	// Pack as many lines into each datagram as will fit
+	packet := ""
+
+	for _, line := range lines {
+		if len(packet) > 0 && len(packet)+len(line)+1 > MaxPushDatagramSize {
+			if _, err := p.conn.Write([]byte(packet)); err != nil {
+				return err
+			}
+
+			packet = ""
+		}
+
+		packet += line + "\n"
+	}
+
+	if len(packet) > 0 {
+		if _, err := p.conn.Write([]byte(packet)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+var pushTestTimestamp = time.Unix(1500000000, 0)
+
+func TestFormatPushLines(t *testing.T) {
+	load := newGaugeMetric("cpu", "load1", "", 1.5)
+	disk := newGaugeMetric("disk", "available_bytes", "", 1024, MetricLabel{Name: "mount", Value: "/mnt/my disk,2"})
+	hostTags := []MetricLabel{{Name: "host", Value: "box"}}
+
+	tests := []struct {
+		name   string
+		format string
+		prefix string
+		tags   []MetricLabel
+		metric Metric
+		want   string
+	}{
+		{"statsd plain", PushFormatStatsD, "sysdash", nil, load, "sysdash.cpu.load1:1.5|g"},
+		{"statsd prefix", PushFormatStatsD, "my app", nil, load, "my_app.cpu.load1:1.5|g"},
+		{"statsd hostname", PushFormatStatsD, "sysdash", hostTags, load, "sysdash.cpu.load1:1.5|g|#host:box"},
+		{"statsd labels", PushFormatStatsD, "sysdash", hostTags, disk,
+			"sysdash.disk.available_bytes:1024|g|#host:box,mount:/mnt/my_disk_2"},
+		{"graphite plain", PushFormatGraphite, "sysdash", nil, load, "sysdash.cpu.load1 1.5 1500000000"},
+		{"graphite hostname", PushFormatGraphite, "sysdash", hostTags, load, "sysdash.cpu.load1;host=box 1.5 1500000000"},
+		{"graphite labels", PushFormatGraphite, "sysdash", hostTags, disk,
+			"sysdash.disk.available_bytes;host=box;mount=/mnt/my_disk,2 1024 1500000000"},
+		{"graphite empty tag", PushFormatGraphite, "sysdash", []MetricLabel{{Name: "host", Value: ""}}, load,
+			"sysdash.cpu.load1;host=_ 1.5 1500000000"},
+		{"influx plain", PushFormatInflux, "sysdash", nil, load, "sysdash_cpu load1=1.5 1500000000000000000"},
+		{"influx prefix", PushFormatInflux, "my app", nil, load, `my\ app_cpu load1=1.5 1500000000000000000`},
+		{"influx hostname", PushFormatInflux, "sysdash", hostTags, load, "sysdash_cpu,host=box load1=1.5 1500000000000000000"},
+		{"influx escaping", PushFormatInflux, "sysdash", hostTags, disk,
+			`sysdash_disk,host=box,mount=/mnt/my\ disk\,2 available_bytes=1024 1500000000000000000`},
+		{"influx sorted tags", PushFormatInflux, "sysdash", []MetricLabel{{Name: "zone", Value: "a=b"}}, disk,
+			`sysdash_disk,mount=/mnt/my\ disk\,2,zone=a\=b available_bytes=1024 1500000000000000000`},
+		{"influx empty tag", PushFormatInflux, "sysdash", []MetricLabel{{Name: "host", Value: ""}}, load,
+			"sysdash_cpu load1=1.5 1500000000000000000"},
+	}
+
+	for _, test := range tests {
+		lines := formatPushLines(test.format, test.prefix, test.tags, pushTestTimestamp, []Metric{test.metric})
+
+		if len(lines) != 1 || lines[0] != test.want {
+			t.Errorf("%v: got %q, want %q", test.name, lines, test.want)
+		}
+	}
+}
+
+func TestMetricPusherUDP(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	p, err := NewMetricPusher("udp://"+listener.LocalAddr().String(), PushFormatStatsD, "sysdash", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each line is a little over 100 bytes, so they can't all fit in one datagram
+	metrics := make([]Metric, 30)
+	for i := range metrics {
+		metrics[i] = newGaugeMetric("test", strings.Repeat("x", 100), "", float64(i))
+	}
+
+	p.push(pushTestTimestamp, metrics)
+
+	lines := 0
+	packets := 0
+	buf := make([]byte, 65536)
+	listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	for lines < len(metrics) {
+		n, _, err := listener.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("after %d lines: %v", lines, err)
+		}
+
+		if n > MaxPushDatagramSize {
+			t.Errorf("datagram is %d bytes, more than %d", n, MaxPushDatagramSize)
+		}
+
+		packet := string(buf[:n])
+		if !strings.HasSuffix(packet, "\n") {
+			t.Errorf("datagram doesn't end with a whole line: %q", packet)
+		}
+
+		lines += strings.Count(packet, "\n")
+		packets++
+	}
+
+	if lines != len(metrics) {
+		t.Errorf("got %d lines, want %d", lines, len(metrics))
+	}
+
+	if packets < 2 {
+		t.Errorf("got %d datagrams, expected the lines to be split", packets)
+	}
+}
+
+func TestMetricPusherTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	p, err := NewMetricPusher(listener.Addr().String(), PushFormatGraphite, "sysdash", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.push(pushTestTimestamp, []Metric{newGaugeMetric("cpu", "load1", "", 1.5), newGaugeMetric("cpu", "load5", "", 2)})
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	for _, want := range []string{"sysdash.cpu.load1 1.5 1500000000\n", "sysdash.cpu.load5 2 1500000000\n"} {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if line != want {
+			t.Errorf("got %q, want %q", line, want)
+		}
+	}
+}
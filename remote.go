@@ -0,0 +1,340 @@
+package main
+
+/**
+ * Widgets that show a dashboard state collected somewhere else (like by an agent), instead of collecting their own.
+ */
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	ui "github.com/gizak/termui"
+)
+
+////////////////////////////////////////////
+// Widget: Remote
+////////////////////////////////////////////
+
+type RemoteWidget struct {
+	name      string
+	kind      string
+	wrap      bool
+	widget    ui.GridBufferer
+	dashboard *RemoteDashboard
+}
+
+// Something empty to apply a state of that kind to
+func newRemoteGridWidget(kind string) ui.GridBufferer {
+	switch kind {
+	case "paragraph":
+		return ui.NewParagraph("")
+	case "list":
+		return ui.NewList()
+	case "gauge":
+		return ui.NewGauge()
+	case "table":
+		t := ui.NewTable()
+		t.Separator = false
+		return t
+	case "linechart":
+		c := ui.NewLineChart()
+		c.PaddingTop = 1
+		return c
+	case "sparkline":
+		return ui.NewSparklines()
+	}
+
+	return ui.NewCol(12, 0)
+}
+
+func NewRemoteWidget(state WidgetState, dashboard *RemoteDashboard) *RemoteWidget {
+	w := &RemoteWidget{
+		name:      state.Name,
+		kind:      state.Kind,
+		wrap:      state.Wrap,
+		widget:    newRemoteGridWidget(state.Kind),
+		dashboard: dashboard,
+	}
+
+	applyWidgetState(w.widget, state)
+
+	return w
+}
+
+func (w *RemoteWidget) getGridWidget() ui.GridBufferer {
+	return w.widget
+}
+
+func (w *RemoteWidget) update() {
+	if state, ok := w.dashboard.getWidgetState(w.name); ok && state.Kind == w.kind {
+		applyWidgetState(w.widget, state)
+		w.wrap = state.Wrap
+
+		// The text might be empty now
+		w.resize()
+	}
+}
+
+func (w *RemoteWidget) resize() {
+	p, ok := w.widget.(*ui.Paragraph)
+	if !ok {
+		return
+	}
+
+	// Paragraphs don't wrap on their own, and termui never finishes wrapping an empty one
+	if w.wrap && p.Width > 2 && len(p.Text) > 0 {
+		p.WrapLength = p.Width - 2
+	} else {
+		p.WrapLength = 0
+	}
+}
+
+// The reverse of buildWidgetState
+func applyWidgetState(gridWidget ui.GridBufferer, state WidgetState) {
+	applyBlock := func(b *ui.Block) {
+		b.BorderLabel = state.Title
+		b.BorderLabelFg = colorStringToAttribute(state.TitleColor)
+		b.BorderFg = colorStringToAttribute(state.BorderColor)
+		b.Height = state.Height
+	}
+
+	switch w := gridWidget.(type) {
+	case *ui.Paragraph:
+		applyBlock(&w.Block)
+		w.Text = strings.Join(state.Lines, "\n")
+		w.TextFgColor = colorStringToAttribute(state.TextColor)
+
+	case *ui.List:
+		applyBlock(&w.Block)
+		w.Items = state.Lines
+
+	case *ui.Gauge:
+		applyBlock(&w.Block)
+		w.Percent = state.Percent
+		w.Label = state.Label
+		w.LabelAlign = ui.AlignRight
+		w.BarColor = colorStringToAttribute(state.BarColor)
+		w.PercentColor = ui.ColorWhite | ui.AttrBold
+		w.PercentColorHighlighted = w.PercentColor
+
+	case *ui.Table:
+		applyBlock(&w.Block)
+		w.Rows = state.Rows
+
+	case *ui.LineChart:
+		applyBlock(&w.Block)
+		w.Data = state.Series
+		w.DataLabels = state.DataLabels
+		w.AxesColor = colorStringToAttribute(state.AxesColor)
+		w.LineColor = make(map[string]ui.Attribute, len(state.SeriesColor))
+
+		for series, color := range state.SeriesColor {
+			w.LineColor[series] = colorStringToAttribute(color)
+		}
+
+	case *ui.Sparklines:
+		applyBlock(&w.Block)
+		w.Lines = make([]ui.Sparkline, len(state.Series))
+
+		// Share the height between them, less a row each for the title (termui draws the bars a row low without one)
+		height := 1
+		if len(state.Series) > 0 && (state.Height-2)/len(state.Series) > 2 {
+			height = (state.Height-2)/len(state.Series) - 1
+		}
+
+		for i := range w.Lines {
+			line := ui.NewSparkline()
+			line.Height = height
+			line.LineColor = colorStringToAttribute(state.SeriesColor[strconv.Itoa(i)])
+			line.TitleColor = ui.ColorWhite | ui.AttrBold
+
+			if i < len(state.Lines) {
+				line.Title = state.Lines[i]
+			}
+
+			for _, v := range state.Series[strconv.Itoa(i)] {
+				line.Data = append(line.Data, int(v))
+			}
+
+			w.Lines[i] = line
+		}
+
+	case *ui.Row:
+		// Rebuild the column of children, same as the disk column does
+		w.Cols = []*ui.Row{}
+		ir := w
+
+		for _, child := range state.Children {
+			g := newRemoteGridWidget(child.Kind)
+			applyWidgetState(g, child)
+
+			nr := &ui.Row{Span: 12, Widget: g}
+			ir.Cols = []*ui.Row{nr}
+			ir = nr
+		}
+	}
+}
+
+////////////////////////////////////////////
+// Utility: Remote Dashboard
+////////////////////////////////////////////
+
+type RemoteDashboard struct {
+	header  *HeaderWidget
+	widgets []NamedWidget
+	layout  []LayoutRow
+	// What fits the terminal
+	mode        LayoutMode
+	shownLayout []LayoutRow
+	lock        sync.Mutex
+	state       DashboardState
+	changes     chan bool
+}
+
+// Widgets and layout come from the first state, later states just update them
+func NewRemoteDashboard(first DashboardState) *RemoteDashboard {
+	return newRemoteDashboard(first, NewHeaderWidgetWithLabel(first.Header))
+}
+
+// For drawing somewhere other than our terminal (there's no header to size)
+func NewHeadlessRemoteDashboard(first DashboardState) *RemoteDashboard {
+	return newRemoteDashboard(first, nil)
+}
+
+func newRemoteDashboard(first DashboardState, header *HeaderWidget) *RemoteDashboard {
+	d := &RemoteDashboard{
+		header:  header,
+		layout:  first.Layout,
+		state:   first,
+		changes: make(chan bool, 1),
+		widgets: []NamedWidget{},
+	}
+
+	// Everything, until there's a terminal size
+	d.mode = LayoutMode{Hidden: map[string]bool{}}
+	d.shownLayout = d.layout
+
+	if header != nil {
+		d.widgets = append(d.widgets, NamedWidget{"header", header})
+	}
+
+	for _, ws := range first.Widgets {
+		d.widgets = append(d.widgets, NamedWidget{ws.Name, NewRemoteWidget(ws, d)})
+	}
+
+	return d
+}
+
+// Safe to call from other goroutines
+func (d *RemoteDashboard) setState(state DashboardState) {
+	d.lock.Lock()
+	d.state = state
+	d.lock.Unlock()
+
+	select {
+	case d.changes <- true:
+	default:
+	}
+}
+
+// Shown in the header, like when we lose the agent
+func (d *RemoteDashboard) setStatus(status string) {
+	d.lock.Lock()
+	d.state.Header = strings.TrimSpace(d.state.Header + " " + status)
+	d.lock.Unlock()
+
+	select {
+	case d.changes <- true:
+	default:
+	}
+}
+
+func (d *RemoteDashboard) getWidgetState(name string) (WidgetState, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, ws := range d.state.Widgets {
+		if ws.Name == name {
+			return ws, true
+		}
+	}
+
+	return WidgetState{}, false
+}
+
+func (d *RemoteDashboard) getHeader() *HeaderWidget {
+	return d.header
+}
+
+func (d *RemoteDashboard) getWidgets() []CAHWidget {
+	widgets := make([]CAHWidget, len(d.widgets))
+
+	for i, w := range d.widgets {
+		widgets[i] = w.Widget
+	}
+
+	return widgets
+}
+
+func (d *RemoteDashboard) getChanges() <-chan bool {
+	return d.changes
+}
+
+func (d *RemoteDashboard) afterUpdate() {
+	if d.header != nil {
+		d.header.widget.BorderLabel = d.getHeaderLabel()
+	}
+}
+
+func (d *RemoteDashboard) getHeaderLabel() string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.state.Header
+}
+
+func (d *RemoteDashboard) handleEvent(e ui.Event) bool {
+	return false
+}
+
+// Only the layout changes, the widgets are whatever the other end sends
+func (d *RemoteDashboard) setTerminalSize(width int, height int) bool {
+	mode := getLayoutMode(width, height)
+	if mode.equals(d.mode) {
+		return false
+	}
+
+	d.mode = mode
+	d.shownLayout = applyLayoutMode(d.layout, mode)
+
+	return true
+}
+
+func (d *RemoteDashboard) getGridWidget(name string) ui.GridBufferer {
+	for _, w := range d.widgets {
+		if w.Name == name {
+			return w.Widget.getGridWidget()
+		}
+	}
+
+	return nil
+}
+
+func (d *RemoteDashboard) buildRows() []*ui.Row {
+	return buildLayoutRows(d.shownLayout, d.getGridWidget)
+}
+
+func (d *RemoteDashboard) getFocusNames() []string {
+	return getLayoutFocusNames(d.shownLayout, d.getGridWidget)
+}
+
+// The state only has what was drawn, not what's behind it
+func (d *RemoteDashboard) getDetailAt(x int, y int) (string, []string, bool) {
+	return "", nil, false
+}
+
+// Sorting and the like happen where the widgets are, so there's nothing to do here
+func (d *RemoteDashboard) handleFocusedEvent(name string, e ui.Event) bool {
+	return false
+}
@@ -0,0 +1,261 @@
+package main
+
+/**
+ * Screenshots of what's on the screen, as ANSI text, HTML or SVG, for bug reports and chat.
+ */
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	ui "github.com/gizak/termui"
+)
+
+////////////////////////////////////////////
+// Utility: Screenshot Rendering
+////////////////////////////////////////////
+
+// The key that saves one from the terminal
+const ScreenshotKey = "s"
+
+var ScreenshotFormats = []string{"ansi", "html", "svg"}
+
+// For the default colors, roughly a dark terminal
+const (
+	ScreenshotForeground = "#e5e5e5"
+	ScreenshotBackground = "#1e1e1e"
+)
+
+// SVG cell size, in pixels
+const (
+	ScreenshotCellWidth  = 9
+	ScreenshotCellHeight = 18
+	ScreenshotFontSize   = 15
+)
+
+func isScreenshotFormat(format string) bool {
+	for _, f := range ScreenshotFormats {
+		if f == format {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The first 8 are ATTRIBUTE_COLOR_HEX, the next 8 are their bright versions, then the 6x6x6 cube and the grays
+func xterm256ToHex(n int) string {
+	switch {
+	case n < 8:
+		return ATTRIBUTE_COLOR_HEX[n+1][0]
+	case n < 16:
+		return ATTRIBUTE_COLOR_HEX[n-7][1]
+	case n < 232:
+		levels := []int{0, 95, 135, 175, 215, 255}
+		n -= 16
+		return fmt.Sprintf("#%02x%02x%02x", levels[n/36], levels[(n/6)%6], levels[n%6])
+	default:
+		gray := 8 + 10*(n-232)
+		return fmt.Sprintf("#%02x%02x%02x", gray, gray, gray)
+	}
+}
+
+// Empty for the default color
+func screenshotColorHex(attr ui.Attribute) string {
+	color := int(attr & AttributeColorMask)
+
+	if color <= 8 {
+		return attributeToHex(attr)
+	}
+
+	// 256 color mode is off by one, like termbox
+	return xterm256ToHex(color - 1)
+}
+
+type ScreenshotStyle struct {
+	Foreground string
+	Background string
+	Bold       bool
+	Underline  bool
+}
+
+func cellStyle(cell ui.Cell) ScreenshotStyle {
+	style := ScreenshotStyle{
+		Foreground: screenshotColorHex(cell.Fg),
+		Background: screenshotColorHex(cell.Bg),
+		Bold:       cell.Fg&ui.AttrBold != 0,
+		Underline:  cell.Fg&ui.AttrUnderline != 0,
+	}
+
+	if len(style.Foreground) <= 0 {
+		style.Foreground = ScreenshotForeground
+	}
+
+	if cell.Fg&ui.AttrReverse != 0 {
+		style.Foreground, style.Background = style.Background, style.Foreground
+
+		if len(style.Foreground) <= 0 {
+			style.Foreground = ScreenshotBackground
+		}
+	}
+
+	return style
+}
+
+// A stretch of a line that all looks the same
+type ScreenshotRun struct {
+	Column int
+	// In cells, which isn't the number of runes once there are wide ones
+	Width int
+	Text  string
+	Style ScreenshotStyle
+}
+
+func bufferRuns(buf ui.Buffer, width int, y int) []ScreenshotRun {
+	runs := make([]ScreenshotRun, 0)
+
+	for x := 0; x < width; x++ {
+		cell := buf.At(x, y)
+
+		ch := cell.Ch
+		if ch == 0 {
+			ch = ' '
+		}
+
+		// termbox leaves a placeholder in the cells a wide rune covers, which would push the rest of the line over
+		cells := 1
+		if w := runeDisplayWidth(ch); w > 1 {
+			cells = w
+		}
+
+		style := cellStyle(cell)
+
+		if len(runs) > 0 && runs[len(runs)-1].Style == style {
+			runs[len(runs)-1].Text += string(ch)
+			runs[len(runs)-1].Width += cells
+		} else {
+			runs = append(runs, ScreenshotRun{Column: x, Width: cells, Text: string(ch), Style: style})
+		}
+
+		x += cells - 1
+	}
+
+	return runs
+}
+
+func renderBufferHTML(buf ui.Buffer, width int, height int) string {
+	var out strings.Builder
+
+	out.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>sysdash</title>\n</head>\n")
+	fmt.Fprintf(&out, "<body style=\"margin:0;background:%s\">\n", ScreenshotBackground)
+	fmt.Fprintf(&out, "<pre style=\"margin:0;padding:8px;color:%s;background:%s;font-family:monospace;line-height:1.2\">",
+		ScreenshotForeground, ScreenshotBackground)
+
+	for y := 0; y < height; y++ {
+		for _, run := range bufferRuns(buf, width, y) {
+			styles := []string{"color:" + run.Style.Foreground}
+
+			if len(run.Style.Background) > 0 {
+				styles = append(styles, "background:"+run.Style.Background)
+			}
+			if run.Style.Bold {
+				styles = append(styles, "font-weight:bold")
+			}
+			if run.Style.Underline {
+				styles = append(styles, "text-decoration:underline")
+			}
+
+			fmt.Fprintf(&out, "<span style=\"%s\">%s</span>", strings.Join(styles, ";"), html.EscapeString(run.Text))
+		}
+
+		out.WriteString("\n")
+	}
+
+	out.WriteString("</pre>\n</body>\n</html>\n")
+
+	return out.String()
+}
+
+func renderBufferSVG(buf ui.Buffer, width int, height int) string {
+	var out strings.Builder
+
+	fmt.Fprintf(&out, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"monospace\" font-size=\"%d\">\n",
+		width*ScreenshotCellWidth, height*ScreenshotCellHeight, ScreenshotFontSize)
+	fmt.Fprintf(&out, "<rect width=\"100%%\" height=\"100%%\" fill=\"%s\"/>\n", ScreenshotBackground)
+
+	for y := 0; y < height; y++ {
+		top := y * ScreenshotCellHeight
+
+		for _, run := range bufferRuns(buf, width, y) {
+			left := run.Column * ScreenshotCellWidth
+			length := run.Width * ScreenshotCellWidth
+
+			if len(run.Style.Background) > 0 {
+				fmt.Fprintf(&out, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n",
+					left, top, length, ScreenshotCellHeight, run.Style.Background)
+			}
+
+			if len(strings.TrimSpace(run.Text)) <= 0 {
+				continue
+			}
+
+			// Stretched to exactly the cells it covers, whatever the font, so the boxes line up
+			attributes := fmt.Sprintf("x=\"%d\" y=\"%d\" textLength=\"%d\" lengthAdjust=\"spacingAndGlyphs\" fill=\"%s\"",
+				left, top+ScreenshotCellHeight-4, length, run.Style.Foreground)
+
+			if run.Style.Bold {
+				attributes += " font-weight=\"bold\""
+			}
+			if run.Style.Underline {
+				attributes += " text-decoration=\"underline\""
+			}
+
+			fmt.Fprintf(&out, "<text %s xml:space=\"preserve\">%s</text>\n", attributes, html.EscapeString(run.Text))
+		}
+	}
+
+	out.WriteString("</svg>\n")
+
+	return out.String()
+}
+
+// The bufferers drawn over a blank screen of that size, in one of ScreenshotFormats
+func renderScreenshot(format string, width int, height int, bs ...ui.Bufferer) (string, error) {
+	return renderBufferScreenshot(format, renderToBuffer(width, height, bs...), width, height, GetColorMode())
+}
+
+// The color mode only matters for ANSI, the others always get the full palette
+func renderBufferScreenshot(format string, buf ui.Buffer, width int, height int, mode ColorMode) (string, error) {
+	switch format {
+	case "ansi":
+		return strings.Join(renderBufferLines(buf, width, height, mode), "\n") + "\n", nil
+	case "html":
+		return renderBufferHTML(buf, width, height), nil
+	case "svg":
+		return renderBufferSVG(buf, width, height), nil
+	}
+
+	return "", fmt.Errorf("unknown screenshot format '%v'", format)
+}
+
+// Into the screenshot directory, named for when it was taken.  Returns where it went.
+func saveScreenshot(format string, width int, height int, bs ...ui.Bufferer) (string, error) {
+	screenshot, err := renderScreenshot(format, width, height, bs...)
+	if err != nil {
+		return "", err
+	}
+
+	dir := GetScreenshotDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("sysdash-%v.%v", time.Now().Format("20060102-150405"), format))
+
+	return path, ioutil.WriteFile(path, []byte(screenshot), 0600)
+}
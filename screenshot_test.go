@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	ui "github.com/gizak/termui"
+)
+
+// go test -run Screenshot -update
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+const (
+	screenshotTestWidth  = 24
+	screenshotTestHeight = 5
+)
+
+// Puts text in a row of cells the way termbox does, a wide rune in one cell with a placeholder after it
+func setScreenshotTestText(buf ui.Buffer, x int, y int, text string, fg ui.Attribute, bg ui.Attribute) {
+	for _, r := range text {
+		buf.Set(x, y, ui.Cell{Ch: r, Fg: fg, Bg: bg})
+		x += runeDisplayWidth(r)
+	}
+}
+
+// A box with a wide title, colors, flags, a 256 color, a background bar and things HTML needs escaped
+func buildScreenshotTestBuffer() ui.Buffer {
+	buf := ui.NewFilledBuffer(0, 0, screenshotTestWidth, screenshotTestHeight, ' ', ui.ColorDefault, ui.ColorDefault)
+	border := ui.ColorCyan | ui.AttrBold
+
+	setScreenshotTestText(buf, 0, 0, "┌", border, ui.ColorDefault)
+	setScreenshotTestText(buf, 1, 0, "Disk 世界", ui.ColorWhite|ui.AttrBold, ui.ColorDefault)
+	setScreenshotTestText(buf, 10, 0, "─────────────┐", border, ui.ColorDefault)
+
+	setScreenshotTestText(buf, 0, 1, "│", border, ui.ColorDefault)
+	setScreenshotTestText(buf, 1, 1, "/home", ui.ColorDefault, ui.ColorDefault)
+	setScreenshotTestText(buf, 7, 1, "[x](fg-red) <&>", ui.ColorRed|ui.AttrUnderline, ui.ColorDefault)
+	setScreenshotTestText(buf, 23, 1, "│", border, ui.ColorDefault)
+
+	setScreenshotTestText(buf, 0, 2, "│", border, ui.ColorDefault)
+	setScreenshotTestText(buf, 1, 2, " 50% ", ui.ColorBlack, ui.ColorGreen)
+	setScreenshotTestText(buf, 7, 2, "warm", ui.Attribute(209), ui.ColorDefault)
+	setScreenshotTestText(buf, 12, 2, "sel", ui.ColorDefault|ui.AttrReverse, ui.ColorDefault)
+	setScreenshotTestText(buf, 16, 2, "😀ok", ui.ColorYellow, ui.ColorDefault)
+	setScreenshotTestText(buf, 23, 2, "│", border, ui.ColorDefault)
+
+	setScreenshotTestText(buf, 0, 3, "│", border, ui.ColorDefault)
+	setScreenshotTestText(buf, 23, 3, "│", border, ui.ColorDefault)
+
+	setScreenshotTestText(buf, 0, 4, "└──────────────────────┘", border, ui.ColorDefault)
+
+	return buf
+}
+
+func TestScreenshotGolden(t *testing.T) {
+	buf := buildScreenshotTestBuffer()
+
+	tests := []struct {
+		format string
+		mode   ColorMode
+		golden string
+	}{
+		{"ansi", ColorMode256, "screenshot.ansi.golden"},
+		{"ansi", ColorModeNone, "screenshot.monochrome.ansi.golden"},
+		{"html", ColorMode256, "screenshot.html.golden"},
+		{"svg", ColorMode256, "screenshot.svg.golden"},
+	}
+
+	for _, test := range tests {
+		got, err := renderBufferScreenshot(test.format, buf, screenshotTestWidth, screenshotTestHeight, test.mode)
+		if err != nil {
+			t.Fatalf("%v: %v", test.golden, err)
+		}
+
+		path := filepath.Join("testdata", test.golden)
+
+		if *updateGolden {
+			if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			continue
+		}
+
+		want, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%v (run with -update to create it)", err)
+		}
+
+		if got != string(want) {
+			t.Errorf("%v doesn't match, run with -update if the change is expected\ngot:\n%v\nwant:\n%v", path, got, want)
+		}
+	}
+}
+
+func TestScreenshotRunsWideRunes(t *testing.T) {
+	runs := bufferRuns(buildScreenshotTestBuffer(), screenshotTestWidth, 0)
+
+	// The title's wide runes cover four cells, so the border after it starts at 10 without a placeholder in the text
+	if len(runs) != 3 {
+		t.Fatalf("got %d runs: %+v", len(runs), runs)
+	}
+
+	if runs[1].Text != "Disk 世界" || runs[1].Column != 1 || runs[1].Width != 9 {
+		t.Errorf("title run is %+v", runs[1])
+	}
+
+	if runs[2].Column != 10 {
+		t.Errorf("border run starts at %d", runs[2].Column)
+	}
+
+	total := 0
+	for _, run := range runs {
+		total += run.Width
+	}
+
+	if total != screenshotTestWidth {
+		t.Errorf("runs cover %d cells, want %d", total, screenshotTestWidth)
+	}
+}
+
+func TestRenderBufferScreenshotUnknownFormat(t *testing.T) {
+	if _, err := renderBufferScreenshot("png", buildScreenshotTestBuffer(), 1, 1, ColorMode256); err == nil {
+		t.Errorf("no error for an unknown format")
+	}
+}
@@ -0,0 +1,638 @@
+package main
+
+/**
+ * A read-only dashboard over SSH.  One headless collector, and every session gets its own copy of the widgets drawn
+ * to its own terminal size, with its own focus.
+ */
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	ui "github.com/gizak/termui"
+	"golang.org/x/crypto/ssh"
+)
+
+////////////////////////////////////////////
+// Utility: SSH Server
+////////////////////////////////////////////
+
+type SSHServer struct {
+	config             *ssh.ServerConfig
+	authorizedKeysPath string
+	lock               sync.Mutex
+	sessions           map[*SSHSession]bool
+}
+
+func NewSSHServer(hostKeyPath string, authorizedKeysPath string) (*SSHServer, error) {
+	hostKey, err := loadOrCreateHostKey(hostKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SSHServer{
+		authorizedKeysPath: authorizedKeysPath,
+		sessions:           make(map[*SSHSession]bool),
+	}
+
+	s.config = &ssh.ServerConfig{
+		PublicKeyCallback: s.checkPublicKey,
+	}
+	s.config.AddHostKey(hostKey)
+
+	latestDashboardState.addListener(s.broadcast)
+
+	return s, nil
+}
+
+func loadOrCreateHostKey(path string) (ssh.Signer, error) {
+	if pemBytes, err := ioutil.ReadFile(path); err == nil {
+		return ssh.ParsePrivateKey(pemBytes)
+	}
+
+	log.Printf("Creating SSH host key at %v", path)
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := ssh.MarshalPrivateKey(privateKey, "sysdash host key")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, err
+	}
+
+	return ssh.NewSignerFromKey(privateKey)
+}
+
+// Reads the file every time, so keys can be added without a restart
+func (s *SSHServer) checkPublicKey(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	authorizedKeys, err := ioutil.ReadFile(s.authorizedKeysPath)
+	if err != nil {
+		log.Printf("Error reading authorized keys: %v", err)
+		return nil, err
+	}
+
+	// A line at a time, so one bad line (or a key type we don't know) doesn't lock out everything after it
+	for i, line := range bytes.Split(authorizedKeys, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		authorizedKey, _, _, _, parseErr := ssh.ParseAuthorizedKey(line)
+		if parseErr != nil {
+			log.Printf("Skipping line %d of %v: %v", i+1, s.authorizedKeysPath, parseErr)
+			continue
+		}
+
+		if bytes.Equal(authorizedKey.Marshal(), key.Marshal()) {
+			return &ssh.Permissions{}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown public key for %v", conn.User())
+}
+
+// Every session redraws with the new state
+func (s *SSHServer) broadcast(state DashboardState) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for session := range s.sessions {
+		session.dashboard.setState(state)
+	}
+}
+
+func (s *SSHServer) serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		go s.handleConnection(conn)
+	}
+}
+
+func (s *SSHServer) handleConnection(conn net.Conn) {
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		log.Printf("SSH handshake with %v failed: %v", conn.RemoteAddr(), err)
+		return
+	}
+	defer serverConn.Close()
+
+	log.Printf("SSH connection from %v@%v", serverConn.User(), serverConn.RemoteAddr())
+
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			log.Printf("Error accepting SSH channel: %v", err)
+			continue
+		}
+
+		go s.handleSession(channel, channelRequests)
+	}
+}
+
+type sshPtyRequest struct {
+	Term    string
+	Columns uint32
+	Rows    uint32
+	Width   uint32
+	Height  uint32
+	Modes   string
+}
+
+type sshWindowChange struct {
+	Columns uint32
+	Rows    uint32
+	Width   uint32
+	Height  uint32
+}
+
+func (s *SSHServer) handleSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	var session *SSHSession
+	var pty *sshPtyRequest
+
+	for req := range requests {
+		switch req.Type {
+		case "pty-req":
+			pty = &sshPtyRequest{}
+			ok := ssh.Unmarshal(req.Payload, pty) == nil
+			req.Reply(ok, nil)
+
+		case "window-change":
+			var change sshWindowChange
+			if session != nil && ssh.Unmarshal(req.Payload, &change) == nil {
+				session.sendEvent(ui.Event{
+					Type:    ui.ResizeEvent,
+					ID:      "<Resize>",
+					Payload: ui.Resize{Width: int(change.Columns), Height: int(change.Rows)},
+				})
+			}
+
+		case "shell":
+			if session != nil {
+				// Already running one, turn this down and keep that going
+				req.Reply(false, nil)
+				continue
+			}
+
+			if pty == nil {
+				// It's a TUI, there's nothing to do without a terminal
+				req.Reply(false, nil)
+				fmt.Fprint(channel.Stderr(), "sysdash needs a terminal, try `ssh -t`\r\n")
+				return
+			}
+
+			req.Reply(true, nil)
+
+			// All there is to go on is what the client said TERM is
+			mode := colorModeForTerminal(pty.Term, "", "")
+
+			session = NewSSHSession(channel, int(pty.Columns), int(pty.Rows), mode)
+			s.addSession(session)
+			defer s.removeSession(session)
+
+			go session.run()
+
+		default:
+			// Read-only: no exec, no subsystems, no forwarding
+			req.Reply(false, nil)
+		}
+	}
+}
+
+func (s *SSHServer) addSession(session *SSHSession) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.sessions[session] = true
+}
+
+func (s *SSHServer) removeSession(session *SSHSession) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.sessions, session)
+}
+
+////////////////////////////////////////////
+// Utility: SSH Input
+////////////////////////////////////////////
+
+// How long to wait for the rest of an escape sequence before deciding it was just Esc
+const SSHEscapeTimeout = 100 * time.Millisecond
+
+var sshControlKeys = map[byte]string{
+	'\x03': "<C-c>",
+	'\x04': "<C-d>",
+	'\t':   "<Tab>",
+	'\r':   "<Enter>",
+}
+
+// After ESC [, by parameters and final byte
+var sshCSIKeys = map[string]string{
+	"Z":  "<BackTab>",
+	"A":  "<Up>",
+	"B":  "<Down>",
+	"C":  "<Right>",
+	"D":  "<Left>",
+	"H":  "<Home>",
+	"F":  "<End>",
+	"1~": "<Home>",
+	"4~": "<End>",
+	"5~": "<PageUp>",
+	"6~": "<PageDown>",
+}
+
+// After ESC O, which is what terminals send in application mode
+var sshSS3Keys = map[byte]string{
+	'A': "<Up>",
+	'B': "<Down>",
+	'C': "<Right>",
+	'D': "<Left>",
+	'H': "<Home>",
+	'F': "<End>",
+}
+
+// Where the parser is in an escape sequence
+const (
+	sshInputText = iota
+	// Just saw ESC
+	sshInputEscape
+	// ESC [, until the final byte
+	sshInputCSI
+	// ESC O, for one more byte
+	sshInputSS3
+)
+
+// Keystrokes from a byte stream that can have several keys in one read, or one key split over several
+type SSHInputParser struct {
+	state  int
+	params []byte
+	// The start of a UTF-8 character that hasn't all come in yet
+	partial []byte
+}
+
+func NewSSHInputParser() *SSHInputParser {
+	return &SSHInputParser{}
+}
+
+// The keys that finished in data, as termui event IDs
+func (p *SSHInputParser) feed(data []byte) []string {
+	keys := make([]string, 0)
+
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+
+		switch p.state {
+		case sshInputText:
+			switch {
+			case len(p.partial) > 0 || b >= utf8.RuneSelf:
+				p.partial = append(p.partial, b)
+
+				if utf8.FullRune(p.partial) {
+					r, _ := utf8.DecodeRune(p.partial)
+					if r != utf8.RuneError {
+						keys = append(keys, string(r))
+					}
+
+					p.partial = nil
+				}
+			case b == '\x1b':
+				p.state = sshInputEscape
+			case sshControlKeys[b] != "":
+				keys = append(keys, sshControlKeys[b])
+			case b < ' ' || b == '\x7f':
+				// Nothing we do anything with
+			default:
+				keys = append(keys, string(rune(b)))
+			}
+
+		case sshInputEscape:
+			switch b {
+			case '[':
+				p.params = p.params[:0]
+				p.state = sshInputCSI
+			case 'O':
+				p.state = sshInputSS3
+			case '\x1b':
+				// Esc hit twice
+				keys = append(keys, "<Escape>")
+			default:
+				// Esc, then something else (or Alt and something), which gets looked at again as itself
+				keys = append(keys, "<Escape>")
+				p.state = sshInputText
+				i--
+			}
+
+		case sshInputCSI:
+			switch {
+			case b >= 0x40 && b <= 0x7e:
+				if key, ok := sshCSIKeys[string(p.params)+string(b)]; ok {
+					keys = append(keys, key)
+				}
+
+				p.state = sshInputText
+			case b < 0x20 || b > 0x7e:
+				// Not a real sequence, give up on it
+				p.state = sshInputText
+			default:
+				p.params = append(p.params, b)
+			}
+
+		case sshInputSS3:
+			if key, ok := sshSS3Keys[b]; ok {
+				keys = append(keys, key)
+			}
+
+			p.state = sshInputText
+		}
+	}
+
+	return keys
+}
+
+// Partway through an escape sequence, so flush should be called if nothing else comes soon
+func (p *SSHInputParser) isInSequence() bool {
+	return p.state != sshInputText
+}
+
+// Nothing else came: a lone ESC was the Esc key, and half a sequence is thrown away
+func (p *SSHInputParser) flush() []string {
+	keys := make([]string, 0)
+
+	if p.state == sshInputEscape {
+		keys = append(keys, "<Escape>")
+	}
+
+	p.state = sshInputText
+
+	return keys
+}
+
+////////////////////////////////////////////
+// Utility: SSH Session
+////////////////////////////////////////////
+
+const SSHSessionHelp = "read-only, Tab: focus, Enter: zoom, PgUp/PgDn: scroll, q: quit"
+
+type SSHSession struct {
+	channel   ssh.Channel
+	dashboard *RemoteDashboard
+	frame     *ui.Paragraph
+	grid      *ui.Grid
+	viewport  *Viewport
+	screen    *ANSIScreen
+	events    chan ui.Event
+	done      chan bool
+	focus     *WidgetFocus
+}
+
+func NewSSHSession(channel ssh.Channel, width int, height int, mode ColorMode) *SSHSession {
+	dashboard := NewHeadlessRemoteDashboard(latestDashboardState.get())
+
+	frame := ui.NewParagraph("")
+	frame.BorderFg = ui.ColorCyan | ui.AttrBold
+
+	grid := ui.NewGrid()
+
+	s := &SSHSession{
+		channel:   channel,
+		dashboard: dashboard,
+		frame:     frame,
+		grid:      grid,
+		viewport:  NewViewport(grid),
+		screen:    NewANSIScreen(width, height, mode),
+		events:    make(chan ui.Event, 16),
+		done:      make(chan bool),
+		focus:     NewWidgetFocus(),
+	}
+
+	s.resize(width, height)
+
+	return s
+}
+
+func (s *SSHSession) sendEvent(e ui.Event) {
+	select {
+	case s.events <- e:
+	case <-s.done:
+	}
+}
+
+// Turns keystrokes into the same event names termui uses
+func (s *SSHSession) readInput() {
+	defer close(s.done)
+
+	// Reads happen off to the side, so a lone Esc can time out while waiting for the rest of a sequence
+	chunks := make(chan []byte)
+
+	go func() {
+		defer close(chunks)
+
+		for {
+			input := make([]byte, 256)
+
+			n, err := s.channel.Read(input)
+			if err != nil {
+				return
+			}
+
+			chunks <- input[:n]
+		}
+	}()
+
+	parser := NewSSHInputParser()
+	var escapeTimeout <-chan time.Time
+
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				return
+			}
+
+			s.sendKeys(parser.feed(chunk))
+		case <-escapeTimeout:
+			s.sendKeys(parser.flush())
+		}
+
+		escapeTimeout = nil
+		if parser.isInSequence() {
+			escapeTimeout = time.After(SSHEscapeTimeout)
+		}
+	}
+}
+
+func (s *SSHSession) sendKeys(keys []string) {
+	for _, key := range keys {
+		select {
+		case s.events <- ui.Event{Type: ui.KeyboardEvent, ID: key}:
+		default:
+			// Mashing keys faster than we draw, drop some
+		}
+	}
+}
+
+func (s *SSHSession) run() {
+	// Alternate screen, no cursor
+	fmt.Fprint(s.channel, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(s.channel, "\x1b[?25h\x1b[?1049l")
+
+	go s.readInput()
+
+	// Catch up on anything that changed while we were setting up
+	s.update()
+
+	for {
+		select {
+		case e := <-s.events:
+			if !s.handleEvent(e) {
+				s.channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+				s.channel.Close()
+				return
+			}
+
+			s.render()
+		case <-s.dashboard.getChanges():
+			s.update()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Returns false when it's time to go
+func (s *SSHSession) handleEvent(e ui.Event) bool {
+	names := s.dashboard.getFocusNames()
+
+	switch e.ID {
+	case "q", "<C-c>", "<C-d>":
+		return false
+	case "<Resize>":
+		payload := e.Payload.(ui.Resize)
+		s.resize(payload.Width, payload.Height)
+	case "<Tab>":
+		s.focus.next(len(names))
+	case "<BackTab>":
+		s.focus.previous(len(names))
+	case "<Enter>":
+		s.focus.toggleZoom()
+	case "<Escape>":
+		s.focus.unzoom()
+	default:
+		s.viewport.handleEvent(e)
+	}
+
+	s.update()
+
+	return true
+}
+
+func (s *SSHSession) resize(width int, height int) {
+	s.frame.Width = width
+	s.frame.Height = height
+	s.grid.X = 1
+	s.grid.Y = 1
+	s.grid.Width = width - 2
+	s.viewport.setArea(1, 1, width-2, height-2)
+
+	s.screen.resize(width, height)
+	s.dashboard.setTerminalSize(width, height)
+
+	for _, w := range s.dashboard.getWidgets() {
+		w.resize()
+	}
+}
+
+func (s *SSHSession) update() {
+	// The widgets put their own colors and sizes back, then focusing changes them again
+	s.focus.restore()
+
+	for _, w := range s.dashboard.getWidgets() {
+		w.update()
+	}
+
+	s.frame.BorderLabel = fmt.Sprintf("%v (%v)", s.dashboard.getHeaderLabel(), SSHSessionHelp)
+
+	s.grid.Rows = s.focus.buildRows(s.dashboard, s.frame.Height-2)
+	s.viewport.align()
+
+	// Paragraphs wrap to whatever width the grid just gave them
+	for _, w := range s.dashboard.getWidgets() {
+		w.resize()
+	}
+
+	s.render()
+}
+
+func (s *SSHSession) render() {
+	fmt.Fprint(s.channel, s.screen.draw(s.frame, s.viewport))
+}
+
+////////////////////////////////////////////
+// Commands: serve-ssh
+////////////////////////////////////////////
+
+func runSSHServer() {
+	startHistory()
+
+	collector := NewHeadlessCollector()
+
+	server, err := NewSSHServer(GetSSHHostKeyPath(), GetSSHAuthorizedKeysPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting up SSH server: %v\n", err)
+		os.Exit(1)
+	}
+
+	listener, err := net.Listen("tcp", GetSSHListenAddress())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listening for SSH: %v\n", err)
+		os.Exit(1)
+	}
+
+	go func() {
+		err := server.serve(listener)
+		log.Printf("SSH server stopped: %v", err)
+	}()
+
+	startExporters()
+
+	ticker := time.NewTicker(AgentUpdateInterval)
+
+	for range ticker.C {
+		collector.update()
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSHInputParser(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   []string
+	}{
+		{"plain keys", []string{"q"}, []string{"q"}},
+		{"several keys in one read", []string{"ab\t"}, []string{"a", "b", "<Tab>"}},
+		{"sequences in one read", []string{"\x1b[5~\x1b[6~\x1b[Z"}, []string{"<PageUp>", "<PageDown>", "<BackTab>"}},
+		{"sequence split after ESC", []string{"\x1b", "[5~"}, []string{"<PageUp>"}},
+		{"sequence split in the middle", []string{"\x1b[", "6", "~q"}, []string{"<PageDown>", "q"}},
+		{"application mode", []string{"\x1bOH\x1bOF"}, []string{"<Home>", "<End>"}},
+		{"arrows", []string{"\x1b[A\x1b[D"}, []string{"<Up>", "<Left>"}},
+		{"escape then a key", []string{"\x1bq"}, []string{"<Escape>", "q"}},
+		{"escape twice", []string{"\x1b\x1b[H"}, []string{"<Escape>", "<Home>"}},
+		{"unknown sequence", []string{"\x1b[99xq"}, []string{"q"}},
+		{"controls", []string{"\r\x03\x04\x7f\x01"}, []string{"<Enter>", "<C-c>", "<C-d>"}},
+		{"split UTF-8", []string{"\xc3", "\xa9\xe2\x82", "\xac"}, []string{"é", "€"}},
+	}
+
+	for _, test := range tests {
+		parser := NewSSHInputParser()
+		keys := make([]string, 0)
+
+		for _, chunk := range test.chunks {
+			keys = append(keys, parser.feed([]byte(chunk))...)
+		}
+
+		if !reflect.DeepEqual(keys, test.want) {
+			t.Errorf("%v: got %q, want %q", test.name, keys, test.want)
+		}
+
+		if parser.isInSequence() {
+			t.Errorf("%v: still in a sequence", test.name)
+		}
+	}
+}
+
+func TestSSHInputParserFlush(t *testing.T) {
+	parser := NewSSHInputParser()
+
+	if keys := parser.feed([]byte("\x1b")); len(keys) != 0 || !parser.isInSequence() {
+		t.Fatalf("lone ESC gave %q before the timeout", keys)
+	}
+
+	if keys := parser.flush(); !reflect.DeepEqual(keys, []string{"<Escape>"}) {
+		t.Errorf("lone ESC flushed to %q", keys)
+	}
+
+	// Half a sequence that never finishes is dropped
+	parser.feed([]byte("\x1b[5"))
+
+	if keys := parser.flush(); len(keys) != 0 {
+		t.Errorf("half a sequence flushed to %q", keys)
+	}
+
+	if keys := parser.feed([]byte("~")); !reflect.DeepEqual(keys, []string{"~"}) {
+		t.Errorf("after flushing got %q", keys)
+	}
+}
+
+type sshTestConnMetadata struct {
+	ssh.ConnMetadata
+}
+
+func (sshTestConnMetadata) User() string {
+	return "test"
+}
+
+func newSSHTestPublicKey(t *testing.T) ssh.PublicKey {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func TestCheckPublicKeySkipsBadLines(t *testing.T) {
+	listed := newSSHTestPublicKey(t)
+	unlisted := newSSHTestPublicKey(t)
+
+	authorizedKeys := "# a comment\n\nssh-unknown AAAA not a key\ngarbage\n" + string(ssh.MarshalAuthorizedKey(listed))
+
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	if err := ioutil.WriteFile(path, []byte(authorizedKeys), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &SSHServer{authorizedKeysPath: path}
+
+	if _, err := s.checkPublicKey(sshTestConnMetadata{}, listed); err != nil {
+		t.Errorf("key after bad lines was refused: %v", err)
+	}
+
+	if _, err := s.checkPublicKey(sshTestConnMetadata{}, unlisted); err == nil {
+		t.Errorf("unlisted key was let in")
+	}
+}
+
+// A server on a local port and a client that's logged in to it
+func newSSHTestClient(t *testing.T) *ssh.Client {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	authorizedKeysPath := filepath.Join(dir, "authorized_keys")
+	if err := ioutil.WriteFile(authorizedKeysPath, ssh.MarshalAuthorizedKey(signer.PublicKey()), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := NewSSHServer(filepath.Join(dir, "host_key"), authorizedKeysPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go server.serve(listener)
+
+	config := &ssh.ClientConfig{
+		User:            "test",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+
+	client, err := ssh.Dial("tcp", listener.Addr().String(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
+func openSSHTestChannel(t *testing.T, client *ssh.Client) ssh.Channel {
+	channel, requests, err := client.OpenChannel("session", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go ssh.DiscardRequests(requests)
+
+	return channel
+}
+
+func TestSSHSessionSecondShellRefused(t *testing.T) {
+	channel := openSSHTestChannel(t, newSSHTestClient(t))
+
+	// Whatever the dashboard draws
+	go ioutil.ReadAll(channel)
+
+	pty := ssh.Marshal(sshPtyRequest{Term: "xterm-256color", Columns: 80, Rows: 24})
+	if ok, err := channel.SendRequest("pty-req", true, pty); !ok || err != nil {
+		t.Fatalf("pty-req: %v %v", ok, err)
+	}
+
+	if ok, err := channel.SendRequest("shell", true, nil); !ok || err != nil {
+		t.Fatalf("first shell: %v %v", ok, err)
+	}
+
+	if ok, err := channel.SendRequest("shell", true, nil); ok || err != nil {
+		t.Errorf("second shell: %v %v", ok, err)
+	}
+
+	// Still up and answering
+	if ok, err := channel.SendRequest("exec", true, ssh.Marshal(struct{ Command string }{"ls"})); ok || err != nil {
+		t.Errorf("exec after the second shell: %v %v", ok, err)
+	}
+
+	if _, err := channel.Write([]byte("q")); err != nil {
+		t.Errorf("writing to the session: %v", err)
+	}
+}
+
+func TestSSHSessionShellWithoutPty(t *testing.T) {
+	channel := openSSHTestChannel(t, newSSHTestClient(t))
+
+	if ok, err := channel.SendRequest("shell", true, nil); ok || err != nil {
+		t.Errorf("shell without a pty: %v %v", ok, err)
+	}
+
+	// Says why, then hangs up
+	stderr, _ := ioutil.ReadAll(channel.Stderr())
+	if !strings.Contains(string(stderr), "needs a terminal") {
+		t.Errorf("stderr is %q", stderr)
+	}
+
+	if _, err := ioutil.ReadAll(channel); err != nil {
+		t.Errorf("reading until the session closed: %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+/**
+ * A serializable copy of what's on the dashboard, for showing it somewhere other than this terminal.
+ */
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ui "github.com/gizak/termui"
+)
+
+////////////////////////////////////////////
+// Utility: Widget State
+////////////////////////////////////////////
+
+// Text fields keep the termui [text](fg-color) markup, colors use the same fg-color strings
+type WidgetState struct {
+	Name        string               `json:"name"`
+	Kind        string               `json:"kind"`
+	Title       string               `json:"title,omitempty"`
+	TitleColor  string               `json:"titleColor,omitempty"`
+	BorderColor string               `json:"borderColor,omitempty"`
+	TextColor   string               `json:"textColor,omitempty"`
+	Wrap        bool                 `json:"wrap,omitempty"`
+	Height      int                  `json:"height"`
+	Lines       []string             `json:"lines,omitempty"`
+	Rows        [][]string           `json:"rows,omitempty"`
+	Percent     int                  `json:"percent,omitempty"`
+	Label       string               `json:"label,omitempty"`
+	BarColor    string               `json:"barColor,omitempty"`
+	Series      map[string][]float64 `json:"series,omitempty"`
+	SeriesColor map[string]string    `json:"seriesColor,omitempty"`
+	DataLabels  []string             `json:"dataLabels,omitempty"`
+	AxesColor   string               `json:"axesColor,omitempty"`
+	Children    []WidgetState        `json:"children,omitempty"`
+}
+
+func buildWidgetState(name string, gridWidget ui.GridBufferer) WidgetState {
+	state := WidgetState{
+		Name:   name,
+		Height: gridWidget.GetHeight(),
+	}
+
+	setBlock := func(b *ui.Block) {
+		state.Title = b.BorderLabel
+		state.TitleColor = attributeToColorString(b.BorderLabelFg, "fg")
+		state.BorderColor = attributeToColorString(b.BorderFg, "fg")
+	}
+
+	switch w := gridWidget.(type) {
+	case *ANSIParagraph:
+		// The markup copy of the text, colors cut down to what markup has names for
+		return buildWidgetState(name, &w.Paragraph)
+
+	case *ui.Paragraph:
+		setBlock(&w.Block)
+		state.Kind = "paragraph"
+		state.Lines = strings.Split(w.Text, "\n")
+		state.TextColor = attributeToColorString(w.TextFgColor, "fg")
+		state.Wrap = w.WrapLength != 0
+
+	case *ui.List:
+		setBlock(&w.Block)
+		state.Kind = "list"
+		state.Lines = append([]string{}, w.Items...)
+
+	case *ui.Gauge:
+		setBlock(&w.Block)
+		state.Kind = "gauge"
+		state.Percent = w.Percent
+		state.Label = strings.Replace(w.Label, "{{percent}}", strconv.Itoa(w.Percent), -1)
+		state.BarColor = attributeToColorString(w.BarColor, "fg")
+
+	case *ui.Table:
+		setBlock(&w.Block)
+		state.Kind = "table"
+		state.Rows = make([][]string, len(w.Rows))
+
+		for i, row := range w.Rows {
+			state.Rows[i] = append([]string{}, row...)
+		}
+
+	case *ui.LineChart:
+		setBlock(&w.Block)
+		state.Kind = "linechart"
+		state.Series = make(map[string][]float64, len(w.Data))
+		state.SeriesColor = make(map[string]string, len(w.LineColor))
+		state.DataLabels = append([]string{}, w.DataLabels...)
+		state.AxesColor = attributeToColorString(w.AxesColor, "fg")
+
+		// The widgets keep appending to these, so copy them
+		for series, data := range w.Data {
+			state.Series[series] = append([]float64{}, data...)
+		}
+
+		for series, color := range w.LineColor {
+			state.SeriesColor[series] = attributeToColorString(color, "fg")
+		}
+
+	case *ui.Sparklines:
+		setBlock(&w.Block)
+		state.Kind = "sparkline"
+		state.Series = make(map[string][]float64, len(w.Lines))
+		state.SeriesColor = make(map[string]string, len(w.Lines))
+
+		// Series by position, with their titles in the lines
+		for i, line := range w.Lines {
+			state.Lines = append(state.Lines, line.Title)
+
+			data := make([]float64, len(line.Data))
+			for j, v := range line.Data {
+				data[j] = float64(v)
+			}
+
+			state.Series[strconv.Itoa(i)] = data
+			state.SeriesColor[strconv.Itoa(i)] = attributeToColorString(line.LineColor, "fg")
+		}
+
+	case *ui.Row:
+		state.Kind = "column"
+
+		// Columns are nested rows, one widget each
+		for r := w; r != nil; {
+			if r.Widget != nil {
+				state.Children = append(state.Children, buildWidgetState(name, r.Widget))
+			}
+
+			if len(r.Cols) > 0 {
+				r = r.Cols[0]
+			} else {
+				r = nil
+			}
+		}
+	}
+
+	return state
+}
+
+////////////////////////////////////////////
+// Utility: Dashboard State
+////////////////////////////////////////////
+
+type RepoState struct {
+	Name     string         `json:"name"`
+	FullPath string         `json:"fullPath"`
+	HomePath string         `json:"homePath"`
+	Branch   string         `json:"branch"`
+	Status   string         `json:"status"`
+	Counts   map[string]int `json:"counts"`
+}
+
+type DashboardState struct {
+	Timestamp time.Time     `json:"timestamp"`
+	Header    string        `json:"header"`
+	Layout    []LayoutRow   `json:"layout"`
+	Widgets   []WidgetState `json:"widgets"`
+	Repos     []RepoState   `json:"repos"`
+	Disks     []DiskUsage   `json:"disks"`
+}
+
+func buildDashboardState(d *Dashboard) DashboardState {
+	state := DashboardState{
+		Timestamp: time.Now(),
+		Header:    d.userHostHeader,
+		Layout:    d.layout,
+		Widgets:   make([]WidgetState, 0, len(d.widgets)),
+		Repos:     make([]RepoState, 0),
+		Disks:     make([]DiskUsage, 0, len(cachedDiskUsage.LastUsage)),
+	}
+
+	for _, w := range d.widgets {
+		if w.Name == "header" {
+			continue
+		}
+
+		state.Widgets = append(state.Widgets, buildWidgetState(w.Name, w.Widget.getGridWidget()))
+	}
+
+	for _, repo := range getCachedGitRepos().Repos {
+		counts := make(map[string]int, len(repo.StatusCounts))
+
+		for key, count := range repo.StatusCounts {
+			counts[RepoStatusFieldDefinitions[key].Name] = count
+		}
+
+		state.Repos = append(state.Repos, RepoState{
+			Name:     repo.Name,
+			FullPath: repo.FullPath,
+			HomePath: repo.HomePath,
+			Branch:   repo.BranchStatus,
+			Status:   repo.Status,
+			Counts:   counts,
+		})
+	}
+
+	for _, disk := range cachedDiskUsage.LastUsage {
+		state.Disks = append(state.Disks, disk)
+	}
+
+	sort.Slice(state.Disks, func(i, j int) bool { return state.Disks[i].MountPoint < state.Disks[j].MountPoint })
+
+	return state
+}
+
+////////////////////////////////////////////
+// Utility: Latest Dashboard State
+////////////////////////////////////////////
+
+// Called (from the rendering loop) each time a new state is recorded, so don't block
+type DashboardStateListener func(state DashboardState)
+
+// The rendering loop records here after every update, servers read from here (from other goroutines)
+type DashboardStateSnapshot struct {
+	lock      sync.RWMutex
+	state     DashboardState
+	listeners []DashboardStateListener
+}
+
+func (s *DashboardStateSnapshot) record(d *Dashboard) {
+	state := buildDashboardState(d)
+
+	s.lock.Lock()
+	s.state = state
+	listeners := s.listeners
+	s.lock.Unlock()
+
+	for _, listener := range listeners {
+		listener(state)
+	}
+}
+
+func (s *DashboardStateSnapshot) addListener(listener DashboardStateListener) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.listeners = append(s.listeners, listener)
+}
+
+func (s *DashboardStateSnapshot) get() DashboardState {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.state
+}
+
+var latestDashboardState = &DashboardStateSnapshot{}
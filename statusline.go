@@ -0,0 +1,284 @@
+package main
+
+/**
+ * A one line summary for status bars (tmux, i3bar, waybar), for when a whole screen is too much.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+////////////////////////////////////////////
+// Utility: Status Segments
+////////////////////////////////////////////
+
+// Mounts with less free than this get a segment
+const StatusLineDiskWarningPercent = 25
+
+type StatusSegment struct {
+	Name string
+	Text string
+	// Same fg-color strings as the widgets, each target maps them to its own colors
+	Color    string
+	Severity Severity
+}
+
+func buildStatusSegments(summary MetricSummary) []StatusSegment {
+	segments := make([]StatusSegment, 0)
+
+	// The marker (when they're on) and the theme's color for severity
+	newSegment := func(name string, text string, severity Severity, color string) StatusSegment {
+		return StatusSegment{Name: name, Text: withSeverityMarker(text, severity), Color: color, Severity: severity}
+	}
+
+	if summary.HasBattery {
+		charging := ""
+		if summary.BatteryCharging {
+			charging = "+"
+		}
+
+		segments = append(segments, newSegment("battery", fmt.Sprintf("BAT %0.0f%%%s", summary.BatteryPercent, charging),
+			percentToSeverity(int(summary.BatteryPercent), 0, 100, false),
+			percentToAttributeString(int(summary.BatteryPercent), 0, 100, false)))
+	}
+
+	loadText := fmt.Sprintf("load %0.2f", summary.Load5)
+	if summary.Processors > 0 {
+		loadPercent := int(100 * summary.Load5 / float64(summary.Processors))
+		segments = append(segments, newSegment("load", loadText, percentToSeverity(loadPercent, 0, 100, true),
+			percentToAttributeString(loadPercent, 0, 100, true)))
+	} else {
+		// Nothing to compare it to
+		segments = append(segments, StatusSegment{Name: "load", Text: loadText, Color: "fg-white"})
+	}
+
+	mountPoints := make([]string, 0, len(summary.DiskFreePercent))
+	for mountPoint := range summary.DiskFreePercent {
+		mountPoints = append(mountPoints, mountPoint)
+	}
+	sort.Strings(mountPoints)
+
+	for _, mountPoint := range mountPoints {
+		free := summary.DiskFreePercent[mountPoint]
+
+		if free < StatusLineDiskWarningPercent {
+			segments = append(segments, newSegment("disk", fmt.Sprintf("%v %0.0f%% free", mountPoint, free),
+				percentToSeverity(int(free), 0, 100, false), percentToAttributeString(int(free), 0, 100, false)))
+		}
+	}
+
+	if summary.HasKerberos {
+		if summary.KerberosValid {
+			segments = append(segments, newSegment("kerberos", "krb OK", SeverityOK, severityToColorString(SeverityOK)))
+		} else {
+			segments = append(segments, newSegment("kerberos", "krb NO TICKET", SeverityCritical,
+				severityToColorString(SeverityCritical)))
+		}
+	}
+
+	if summary.DirtyRepos > 0 {
+		segments = append(segments, newSegment("repos", fmt.Sprintf("%d dirty", summary.DirtyRepos), SeverityWarning,
+			severityToColorString(SeverityWarning)))
+	} else {
+		segments = append(segments, newSegment("repos", "repos clean", SeverityOK, severityToColorString(SeverityOK)))
+	}
+
+	return segments
+}
+
+// For NO_COLOR, where the markers have to say it all
+func withoutColors(segments []StatusSegment) []StatusSegment {
+	plain := make([]StatusSegment, len(segments))
+
+	for i, segment := range segments {
+		plain[i] = segment
+		plain[i].Color = ""
+	}
+
+	return plain
+}
+
+////////////////////////////////////////////
+// Utility: Status Line Formats
+////////////////////////////////////////////
+
+const (
+	StatusLineTargetTmux   = "tmux"
+	StatusLineTargetI3bar  = "i3bar"
+	StatusLineTargetWaybar = "waybar"
+)
+
+// Like "#[fg=red,bold]"
+func colorStringToTmux(colorString string) string {
+	styles := make([]string, 0)
+
+	for _, part := range strings.Split(colorString, ",") {
+		switch part {
+		case "":
+		case "fg-bold", "bg-bold":
+			styles = append(styles, "bold")
+		case "fg-underline", "bg-underline":
+			styles = append(styles, "underscore")
+		case "fg-reverse", "bg-reverse":
+			styles = append(styles, "reverse")
+		default:
+			// fg-red is fg=red
+			styles = append(styles, strings.Replace(part, "-", "=", 1))
+		}
+	}
+
+	if len(styles) == 0 {
+		return ""
+	}
+
+	return "#[" + strings.Join(styles, ",") + "]"
+}
+
+func formatTmuxStatusLine(segments []StatusSegment) string {
+	parts := make([]string, len(segments))
+
+	for i, segment := range segments {
+		// # starts a format in tmux
+		text := strings.Replace(segment.Text, "#", "##", -1)
+		parts[i] = colorStringToTmux(segment.Color) + text + "#[default]"
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// https://i3wm.org/docs/i3bar-protocol.html
+type i3barBlock struct {
+	Name     string `json:"name"`
+	FullText string `json:"full_text"`
+	Color    string `json:"color,omitempty"`
+	Urgent   bool   `json:"urgent,omitempty"`
+}
+
+// One element of the never-ending array, so it keeps the trailing comma
+func formatI3barStatusLine(segments []StatusSegment) (string, error) {
+	blocks := make([]i3barBlock, len(segments))
+
+	for i, segment := range segments {
+		blocks[i] = i3barBlock{
+			Name:     segment.Name,
+			FullText: segment.Text,
+			Color:    attributeToHex(colorStringToAttribute(segment.Color)),
+			Urgent:   segment.Severity == SeverityCritical,
+		}
+	}
+
+	line, err := json.Marshal(blocks)
+
+	return string(line) + ",", err
+}
+
+// https://github.com/Alexays/Waybar/wiki/Module:-Custom with "return-type": "json"
+type waybarOutput struct {
+	Text    string `json:"text"`
+	Tooltip string `json:"tooltip"`
+	Class   string `json:"class"`
+}
+
+func formatWaybarStatusLine(segments []StatusSegment) (string, error) {
+	texts := make([]string, len(segments))
+	tooltips := make([]string, len(segments))
+	worst := SeverityOK
+
+	for i, segment := range segments {
+		text := html.EscapeString(segment.Text)
+
+		// Pango markup
+		if hex := attributeToHex(colorStringToAttribute(segment.Color)); len(hex) > 0 {
+			text = fmt.Sprintf("<span color='%s'>%s</span>", hex, text)
+		}
+
+		texts[i] = text
+		tooltips[i] = fmt.Sprintf("%v: %v", segment.Name, segment.Text)
+
+		// The whole module gets the worst one
+		if segment.Severity > worst {
+			worst = segment.Severity
+		}
+	}
+
+	line, err := json.Marshal(waybarOutput{
+		Text:    strings.Join(texts, " "),
+		Tooltip: strings.Join(tooltips, "\n"),
+		Class:   worst.String(),
+	})
+
+	return string(line), err
+}
+
+func formatStatusLine(target string, segments []StatusSegment) (string, error) {
+	switch target {
+	case StatusLineTargetTmux:
+		return formatTmuxStatusLine(segments), nil
+	case StatusLineTargetI3bar:
+		return formatI3barStatusLine(segments)
+	case StatusLineTargetWaybar:
+		return formatWaybarStatusLine(segments)
+	}
+
+	return "", fmt.Errorf("unknown status line target '%v'", target)
+}
+
+////////////////////////////////////////////
+// Commands: statusline
+////////////////////////////////////////////
+
+func runStatusLine() {
+	target := GetStatusLineTarget()
+
+	if _, err := formatStatusLine(target, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	collector := NewHeadlessCollector()
+
+	if target == StatusLineTargetI3bar {
+		// The header, then the start of the never-ending array
+		fmt.Println(`{"version":1}`)
+		fmt.Println("[")
+	}
+
+	printStatusLine := func() {
+		_, metrics := latestMetrics.get()
+
+		segments := buildStatusSegments(summarizeMetrics(metrics))
+		if GetColorMode() == ColorModeNone {
+			segments = withoutColors(segments)
+		}
+
+		line, err := formatStatusLine(target, segments)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error formatting status line: %v\n", err)
+			os.Exit(1)
+		}
+
+		if _, err := fmt.Println(line); err != nil {
+			// The bar went away
+			os.Exit(0)
+		}
+	}
+
+	printStatusLine()
+
+	if RunOnce() {
+		return
+	}
+
+	ticker := time.NewTicker(AgentUpdateInterval)
+
+	for range ticker.C {
+		collector.update()
+		printStatusLine()
+	}
+}
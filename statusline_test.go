@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+)
+
+// Critical without bold, a # for tmux, < & for Pango (which JSON escapes again) and no color at all
+var statusLineTestSegments = []StatusSegment{
+	{Name: "load", Text: "load 0.52", Color: "fg-green", Severity: SeverityOK},
+	{Name: "disk", Text: "/ 5% free", Color: "fg-red", Severity: SeverityCritical},
+	{Name: "repos", Text: "#3 <dirty> & more", Color: "fg-yellow,fg-bold", Severity: SeverityWarning},
+	{Name: "plain", Text: "plain", Color: ""},
+}
+
+func TestFormatStatusLine(t *testing.T) {
+	tests := []struct {
+		target   string
+		segments []StatusSegment
+		want     string
+	}{
+		{StatusLineTargetTmux, statusLineTestSegments,
+			"#[fg=green]load 0.52#[default] #[fg=red]/ 5% free#[default] " +
+				"#[fg=yellow,bold]##3 <dirty> & more#[default] plain#[default]"},
+		{StatusLineTargetTmux, []StatusSegment{}, ""},
+		{StatusLineTargetI3bar, statusLineTestSegments,
+			`[{"name":"load","full_text":"load 0.52","color":"#0dbc79"},` +
+				`{"name":"disk","full_text":"/ 5% free","color":"#cd3131","urgent":true},` +
+				`{"name":"repos","full_text":"#3 \u003cdirty\u003e \u0026 more","color":"#f5f543"},` +
+				`{"name":"plain","full_text":"plain"}],`},
+		{StatusLineTargetI3bar, []StatusSegment{}, "[],"},
+		{StatusLineTargetWaybar, statusLineTestSegments,
+			`{"text":"\u003cspan color='#0dbc79'\u003eload 0.52\u003c/span\u003e ` +
+				`\u003cspan color='#cd3131'\u003e/ 5% free\u003c/span\u003e ` +
+				`\u003cspan color='#f5f543'\u003e#3 \u0026lt;dirty\u0026gt; \u0026amp; more\u003c/span\u003e plain",` +
+				`"tooltip":"load: load 0.52\ndisk: / 5% free\nrepos: #3 \u003cdirty\u003e \u0026 more\nplain: plain",` +
+				`"class":"critical"}`},
+		{StatusLineTargetWaybar, []StatusSegment{}, `{"text":"","tooltip":"","class":"ok"}`},
+	}
+
+	for _, test := range tests {
+		got, err := formatStatusLine(test.target, test.segments)
+		if err != nil {
+			t.Errorf("%v: %v", test.target, err)
+		}
+
+		if got != test.want {
+			t.Errorf("%v: got\n%v\nwant\n%v", test.target, got, test.want)
+		}
+	}
+
+	if _, err := formatStatusLine("dzen", statusLineTestSegments); err == nil {
+		t.Errorf("no error for an unknown target")
+	}
+}
+
+func TestColorStringToTmux(t *testing.T) {
+	tests := []struct {
+		color string
+		want  string
+	}{
+		{"", ""},
+		{"fg-red", "#[fg=red]"},
+		{"fg-white,bg-blue", "#[fg=white,bg=blue]"},
+		{"fg-cyan,fg-bold,fg-underline,fg-reverse", "#[fg=cyan,bold,underscore,reverse]"},
+	}
+
+	for _, test := range tests {
+		if got := colorStringToTmux(test.color); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.color, got, test.want)
+		}
+	}
+}
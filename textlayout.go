@@ -0,0 +1,355 @@
+package main
+
+/**
+ * Measuring and cutting text by how wide it is on the terminal, which isn't how many bytes (or runes) it is.
+ */
+
+import (
+	"regexp"
+	"strings"
+
+	ui "github.com/gizak/termui"
+	runewidth "github.com/mattn/go-runewidth"
+)
+
+////////////////////////////////////////////
+// Utility: Markup
+////////////////////////////////////////////
+
+// termui's [text](fg-color) syntax
+var MarkupRegexp = regexp.MustCompile(`\[([^\[\]]*)\]\(([^()]*)\)`)
+
+// Some text, and the markup colors it's drawn in (empty for none)
+type MarkupSegment struct {
+	Text  string
+	Color string
+}
+
+func parseMarkup(markup string) []MarkupSegment {
+	segments := make([]MarkupSegment, 0)
+	last := 0
+
+	for _, match := range MarkupRegexp.FindAllStringSubmatchIndex(markup, -1) {
+		if match[0] > last {
+			segments = append(segments, MarkupSegment{Text: markup[last:match[0]]})
+		}
+
+		segments = append(segments, MarkupSegment{Text: markup[match[2]:match[3]], Color: markup[match[4]:match[5]]})
+		last = match[1]
+	}
+
+	if last < len(markup) {
+		segments = append(segments, MarkupSegment{Text: markup[last:]})
+	}
+
+	return segments
+}
+
+func buildMarkup(segments []MarkupSegment) string {
+	var out strings.Builder
+
+	for _, segment := range segments {
+		if len(segment.Color) > 0 && len(segment.Text) > 0 {
+			out.WriteString("[" + segment.Text + "](" + segment.Color + ")")
+		} else {
+			out.WriteString(segment.Text)
+		}
+	}
+
+	return out.String()
+}
+
+// Just the text that gets drawn
+func stripMarkup(markup string) string {
+	return MarkupRegexp.ReplaceAllString(markup, "$1")
+}
+
+////////////////////////////////////////////
+// Utility: Display Width
+////////////////////////////////////////////
+
+const Ellipsis = "…"
+
+// Wide (East Asian, most emoji) runes take two columns, combining marks take none
+func runeDisplayWidth(r rune) int {
+	return runewidth.RuneWidth(r)
+}
+
+// How many columns it takes up, not counting markup
+func displayWidth(markup string) int {
+	return runewidth.StringWidth(stripMarkup(markup))
+}
+
+// Cut down to width columns, ending with an ellipsis if anything was cut.  Markup stays markup, in whatever color the
+// text that got cut was.
+func truncateToWidth(markup string, width int) string {
+	if displayWidth(markup) <= width {
+		return markup
+	} else if width <= 0 {
+		return ""
+	}
+
+	// Room for the ellipsis
+	budget := width - runewidth.StringWidth(Ellipsis)
+	used := 0
+
+	segments := parseMarkup(markup)
+
+	for i, segment := range segments {
+		var text strings.Builder
+
+		for _, r := range segment.Text {
+			if used+runeDisplayWidth(r) > budget {
+				segments[i].Text = text.String() + Ellipsis
+				return buildMarkup(segments[:i+1])
+			}
+
+			text.WriteRune(r)
+			used += runeDisplayWidth(r)
+		}
+	}
+
+	// Only gets here if the widths disagree, which they shouldn't
+	return buildMarkup(segments)
+}
+
+// Keeps the end instead, which is the interesting part of a path.  Plain text only.
+func truncateStartToWidth(text string, width int) string {
+	if runewidth.StringWidth(text) <= width {
+		return text
+	} else if width <= 0 {
+		return ""
+	}
+
+	runes := []rune(text)
+	budget := width - runewidth.StringWidth(Ellipsis)
+	used := 0
+	start := len(runes)
+
+	for start > 0 && used+runeDisplayWidth(runes[start-1]) <= budget {
+		start--
+		used += runeDisplayWidth(runes[start])
+	}
+
+	return Ellipsis + string(runes[start:])
+}
+
+// Spaces on the right, out to width columns
+func padToWidth(markup string, width int) string {
+	if pad := width - displayWidth(markup); pad > 0 {
+		return markup + strings.Repeat(" ", pad)
+	}
+
+	return markup
+}
+
+// Spaces on the left, out to width columns
+func padLeftToWidth(markup string, width int) string {
+	if pad := width - displayWidth(markup); pad > 0 {
+		return strings.Repeat(" ", pad) + markup
+	}
+
+	return markup
+}
+
+////////////////////////////////////////////
+// Utility: Wrapping
+////////////////////////////////////////////
+
+// A character and the markup colors it's drawn in
+type styledRune struct {
+	r     rune
+	color string
+}
+
+func toStyledRunes(markup string) []styledRune {
+	runes := make([]styledRune, 0, len(markup))
+
+	for _, segment := range parseMarkup(markup) {
+		for _, r := range segment.Text {
+			runes = append(runes, styledRune{r, segment.Color})
+		}
+	}
+
+	return runes
+}
+
+func fromStyledRunes(runes []styledRune) string {
+	segments := make([]MarkupSegment, 0)
+
+	for _, r := range runes {
+		if len(segments) > 0 && segments[len(segments)-1].Color == r.color {
+			segments[len(segments)-1].Text += string(r.r)
+		} else {
+			segments = append(segments, MarkupSegment{Text: string(r.r), Color: r.color})
+		}
+	}
+
+	return buildMarkup(segments)
+}
+
+func styledWidth(runes []styledRune) int {
+	width := 0
+
+	for _, r := range runes {
+		width += runeDisplayWidth(r.r)
+	}
+
+	return width
+}
+
+// Words, and the spaces between them
+func splitStyledWords(runes []styledRune) [][]styledRune {
+	tokens := make([][]styledRune, 0)
+	start := 0
+
+	for i := range runes {
+		if i > start && (runes[i].r == ' ') != (runes[start].r == ' ') {
+			tokens = append(tokens, runes[start:i])
+			start = i
+		}
+	}
+
+	if start < len(runes) {
+		tokens = append(tokens, runes[start:])
+	}
+
+	return tokens
+}
+
+// URLs are no use broken in half, so they get cut short instead
+func isURL(word []styledRune) bool {
+	var text strings.Builder
+
+	for _, r := range word {
+		text.WriteRune(r.r)
+	}
+
+	return strings.Contains(text.String(), "://")
+}
+
+// Word wrapped to width columns, keeping the markup on every line.  Words too long for a line are broken with a hyphen,
+// or cut short with an ellipsis if they're URLs.  Newlines in the text stay.
+func wrapText(markup string, width int) []string {
+	if width < 2 {
+		// No room for a hyphen, nothing sensible to do
+		return strings.Split(markup, "\n")
+	}
+
+	// Split after reading the markup, which can have newlines inside it
+	paragraphs := [][]styledRune{{}}
+
+	for _, r := range toStyledRunes(markup) {
+		if r.r == '\n' {
+			paragraphs = append(paragraphs, []styledRune{})
+		} else {
+			paragraphs[len(paragraphs)-1] = append(paragraphs[len(paragraphs)-1], r)
+		}
+	}
+
+	lines := make([]string, 0)
+
+	for _, paragraph := range paragraphs {
+		line := make([]styledRune, 0)
+		lineWidth := 0
+		// Spaces only go in if a word follows them on the same line
+		var spaces []styledRune
+
+		flush := func() {
+			lines = append(lines, fromStyledRunes(line))
+			line = make([]styledRune, 0)
+			lineWidth = 0
+		}
+
+		for _, token := range splitStyledWords(paragraph) {
+			if token[0].r == ' ' {
+				spaces = token
+				continue
+			}
+
+			tokenWidth := styledWidth(token)
+
+			switch {
+			case lineWidth+styledWidth(spaces)+tokenWidth <= width:
+				// Leading spaces (indenting) count, the ones at a break don't
+				line = append(append(line, spaces...), token...)
+				lineWidth += styledWidth(spaces) + tokenWidth
+
+			case tokenWidth <= width:
+				flush()
+				line = append(line, token...)
+				lineWidth = tokenWidth
+
+			case isURL(token):
+				if lineWidth > 0 {
+					flush()
+				}
+
+				line = toStyledRunes(truncateToWidth(fromStyledRunes(token), width))
+				lineWidth = styledWidth(line)
+
+			default:
+				if lineWidth > 0 {
+					flush()
+				}
+
+				// Whatever fits, a hyphen, and the rest on the next line
+				for styledWidth(token) > width {
+					used, cut := 0, 0
+
+					for cut < len(token) && used+runeDisplayWidth(token[cut].r) <= width-1 {
+						used += runeDisplayWidth(token[cut].r)
+						cut++
+					}
+
+					// A wide character with only a column to spare for it goes on a line of its own, without the hyphen
+					if cut == 0 {
+						line = append(line, token[0])
+						flush()
+						token = token[1:]
+						continue
+					}
+
+					line = append(append(line, token[:cut]...), styledRune{'-', token[cut].color})
+					flush()
+					token = token[cut:]
+				}
+
+				line = append(line, token...)
+				lineWidth = styledWidth(token)
+			}
+
+			spaces = nil
+		}
+
+		flush()
+	}
+
+	return lines
+}
+
+// Inside the border and padding, where the text goes
+func paragraphTextWidth(p *ui.Paragraph) int {
+	width := p.Width - p.PaddingLeft - p.PaddingRight
+
+	if p.Border {
+		width -= 2
+	}
+
+	return width
+}
+
+// Tall enough to show all of lines, at least minHeight
+func fitParagraphHeight(p *ui.Paragraph, lines int, minHeight int) {
+	height := lines + p.PaddingTop + p.PaddingBottom
+
+	if p.Border {
+		height += 2
+	}
+
+	if height < minHeight {
+		height = minHeight
+	}
+
+	p.Height = height
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMarkup(t *testing.T) {
+	markup := "a [red](fg-red) b [](fg-blue)[x]c"
+
+	want := []MarkupSegment{{"a ", ""}, {"red", "fg-red"}, {" b ", ""}, {"", "fg-blue"}, {"[x]c", ""}}
+	if got := parseMarkup(markup); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// Empty colored segments go away
+	if got := buildMarkup(parseMarkup(markup)); got != "a [red](fg-red) b [x]c" {
+		t.Errorf("rebuilt %q", got)
+	}
+
+	if got := stripMarkup(markup); got != "a red b [x]c" {
+		t.Errorf("stripped %q", got)
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		markup string
+		want   int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"世界", 4},
+		{"😀", 2},
+		{"é", 1},
+		{"[red](fg-red) x", 5},
+		{"[世](fg-red)a", 3},
+	}
+
+	for _, test := range tests {
+		if got := displayWidth(test.markup); got != test.want {
+			t.Errorf("%q: got %d, want %d", test.markup, got, test.want)
+		}
+	}
+}
+
+func TestTruncateToWidth(t *testing.T) {
+	tests := []struct {
+		markup string
+		width  int
+		want   string
+	}{
+		{"hello", 5, "hello"},
+		{"hello", 10, "hello"},
+		{"hello world", 8, "hello w…"},
+		{"hello", 0, ""},
+		{"hello", -3, ""},
+		{"hello", 1, "…"},
+		// A wide rune that would go one column over gets left out
+		{"世界abc", 4, "世…"},
+		{"ab世界", 4, "ab…"},
+		{"ab世界", 5, "ab世…"},
+		{"世界", 1, "…"},
+		{"[hello](fg-red) world", 7, "[hello](fg-red) …"},
+		{"[hello world](fg-red)", 6, "[hello…](fg-red)"},
+		{"[ab](fg-red)[cd](fg-blue)", 3, "[ab](fg-red)[…](fg-blue)"},
+		{"[ab](fg-red)[cd](fg-blue)", 4, "[ab](fg-red)[cd](fg-blue)"},
+	}
+
+	for _, test := range tests {
+		got := truncateToWidth(test.markup, test.width)
+
+		if got != test.want {
+			t.Errorf("%q to %d: got %q, want %q", test.markup, test.width, got, test.want)
+		}
+
+		if test.width > 0 && displayWidth(got) > test.width {
+			t.Errorf("%q to %d: %q is %d wide", test.markup, test.width, got, displayWidth(got))
+		}
+	}
+}
+
+func TestTruncateStartToWidth(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  string
+	}{
+		{"/home/user/src/project", 30, "/home/user/src/project"},
+		{"/home/user/src/project", 10, "…c/project"},
+		{"/home", 0, ""},
+		{"/home", 1, "…"},
+		{"/data/世界", 4, "…界"},
+		{"/data/世界", 5, "…世界"},
+		{"/data/世界", 6, "…/世界"},
+	}
+
+	for _, test := range tests {
+		if got := truncateStartToWidth(test.text, test.width); got != test.want {
+			t.Errorf("%q to %d: got %q, want %q", test.text, test.width, got, test.want)
+		}
+	}
+}
+
+func TestPadToWidth(t *testing.T) {
+	if got := padToWidth("[a](fg-red)", 3); got != "[a](fg-red)  " {
+		t.Errorf("padToWidth gave %q", got)
+	}
+
+	if got := padLeftToWidth("世", 3); got != " 世" {
+		t.Errorf("padLeftToWidth gave %q", got)
+	}
+
+	if got := padToWidth("toolong", 3); got != "toolong" {
+		t.Errorf("padToWidth cut %q", got)
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		name   string
+		markup string
+		width  int
+		want   []string
+	}{
+		{"fits", "hello world", 11, []string{"hello world"}},
+		{"words", "hello world foo", 11, []string{"hello world", "foo"}},
+		{"one word a line", "hello world", 5, []string{"hello", "world"}},
+		{"empty", "", 10, []string{""}},
+		{"newlines stay", "a\n\nb", 10, []string{"a", "", "b"}},
+		{"indent kept", "  indented text", 10, []string{"  indented", "text"}},
+		{"spaces at a break dropped", "aaa   bbb", 4, []string{"aaa", "bbb"}},
+		{"trailing spaces dropped", "abc   ", 10, []string{"abc"}},
+		{"long word hyphenated", "abcdefghij", 4, []string{"abc-", "def-", "ghij"}},
+		{"long word on its own line", "hi abcdefgh", 5, []string{"hi", "abcd-", "efgh"}},
+		{"text after a long word", "abcdefg hi", 4, []string{"abc-", "defg", "hi"}},
+		{"URL cut short", "see https://example.com/very/long", 12, []string{"see", "https://exa…"}},
+		{"short URL", "see https://x.io", 20, []string{"see https://x.io"}},
+		{"markup on every line", "[hello world](fg-red)", 5, []string{"[hello](fg-red)", "[world](fg-red)"}},
+		{"hyphen in the word's color", "[abcdef](fg-red)", 4, []string{"[abc-](fg-red)", "[def](fg-red)"}},
+		{"markup with a newline", "[one\ntwo](fg-red) x", 10, []string{"[one](fg-red)", "[two](fg-red) x"}},
+		{"wide runes hyphenated", "世界世界", 5, []string{"世界-", "世界"}},
+		{"wide runes with no room for a hyphen", "世界", 2, []string{"世", "界"}},
+		{"wide rune after a narrow one", "a世界", 2, []string{"a-", "世", "界"}},
+		{"too narrow to wrap", "a b\nc", 1, []string{"a b", "c"}},
+	}
+
+	for _, test := range tests {
+		got := wrapText(test.markup, test.width)
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %q, want %q", test.name, got, test.want)
+		}
+
+		for _, line := range got {
+			if test.width >= 2 && displayWidth(line) > test.width {
+				t.Errorf("%v: %q is wider than %d", test.name, line, test.width)
+			}
+		}
+	}
+}
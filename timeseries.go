@@ -0,0 +1,183 @@
+package main
+
+/**
+ * A fixed-size, timestamped series of values, for anything that graphs or needs the previous reading of a counter.
+ */
+
+import (
+	"time"
+)
+
+////////////////////////////////////////////
+// Utility: Time Series
+////////////////////////////////////////////
+
+type TimePoint struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// A ring buffer: once it's full, adding drops the oldest point
+type TimeSeries struct {
+	points []TimePoint
+	start  int
+	length int
+}
+
+func NewTimeSeries(capacity int) *TimeSeries {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &TimeSeries{
+		points: make([]TimePoint, capacity),
+	}
+}
+
+func (s *TimeSeries) add(timestamp time.Time, value float64) {
+	end := (s.start + s.length) % len(s.points)
+	s.points[end] = TimePoint{Timestamp: timestamp, Value: value}
+
+	if s.length < len(s.points) {
+		s.length++
+	} else {
+		s.start = (s.start + 1) % len(s.points)
+	}
+}
+
+func (s *TimeSeries) len() int {
+	return s.length
+}
+
+func (s *TimeSeries) capacity() int {
+	return len(s.points)
+}
+
+// 0 is the oldest
+func (s *TimeSeries) at(i int) TimePoint {
+	return s.points[(s.start+i)%len(s.points)]
+}
+
+func (s *TimeSeries) last() (TimePoint, bool) {
+	if s.length == 0 {
+		return TimePoint{}, false
+	}
+
+	return s.at(s.length - 1), true
+}
+
+// Oldest first, a copy
+func (s *TimeSeries) all() []TimePoint {
+	return s.lastN(s.length)
+}
+
+// Up to n of the newest points, oldest first
+func (s *TimeSeries) lastN(n int) []TimePoint {
+	if n > s.length {
+		n = s.length
+	} else if n < 0 {
+		n = 0
+	}
+
+	points := make([]TimePoint, n)
+	for i := range points {
+		points[i] = s.at(s.length - n + i)
+	}
+
+	return points
+}
+
+// Everything from the given time on, oldest first
+func (s *TimeSeries) since(from time.Time) []TimePoint {
+	n := 0
+	for n < s.length && !s.at(s.length-n-1).Timestamp.Before(from) {
+		n++
+	}
+
+	return s.lastN(n)
+}
+
+// Everything from the given time up to (not including) until, oldest first
+func (s *TimeSeries) between(from time.Time, until time.Time) []TimePoint {
+	points := make([]TimePoint, 0)
+
+	for _, p := range s.since(from) {
+		if p.Timestamp.Before(until) {
+			points = append(points, p)
+		}
+	}
+
+	return points
+}
+
+////////////////////////////////////////////
+// Utility: Counters
+////////////////////////////////////////////
+
+// For counters (like /proc/stat jiffies): how much the last point went up from the one before it
+func (s *TimeSeries) delta() (float64, bool) {
+	if s.length < 2 {
+		return 0, false
+	}
+
+	return s.at(s.length-1).Value - s.at(s.length-2).Value, true
+}
+
+// Per second, between the last two points
+func (s *TimeSeries) rate() (float64, bool) {
+	if s.length < 2 {
+		return 0, false
+	}
+
+	return pointRate(s.at(s.length-2), s.at(s.length-1))
+}
+
+// Per second rates between each pair of points, stamped with the later one.  Counter resets are left out.
+func (s *TimeSeries) rates() []TimePoint {
+	rates := make([]TimePoint, 0, s.length)
+
+	for i := 1; i < s.length; i++ {
+		current := s.at(i)
+
+		if rate, ok := pointRate(s.at(i-1), current); ok {
+			rates = append(rates, TimePoint{Timestamp: current.Timestamp, Value: rate})
+		}
+	}
+
+	return rates
+}
+
+func pointRate(previous TimePoint, current TimePoint) (float64, bool) {
+	seconds := current.Timestamp.Sub(previous.Timestamp).Seconds()
+
+	// Counters only go up, unless they wrapped or the thing restarted
+	if seconds <= 0 || current.Value < previous.Value {
+		return 0, false
+	}
+
+	return (current.Value - previous.Value) / seconds, true
+}
+
+////////////////////////////////////////////
+// Utility: Chart Data
+////////////////////////////////////////////
+
+func pointValues(points []TimePoint) []float64 {
+	values := make([]float64, len(points))
+
+	for i, p := range points {
+		values[i] = p.Value
+	}
+
+	return values
+}
+
+func pointLabels(points []TimePoint, span time.Duration) []string {
+	labels := make([]string, len(points))
+
+	for i, p := range points {
+		labels[i] = formatChartLabel(p.Timestamp, span)
+	}
+
+	return labels
+}
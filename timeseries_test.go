@@ -0,0 +1,143 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var timeSeriesTestStart = time.Unix(1500000000, 0)
+
+// One point a second, with the given values
+func newTestTimeSeries(capacity int, values ...float64) *TimeSeries {
+	s := NewTimeSeries(capacity)
+
+	for i, v := range values {
+		s.add(timeSeriesTestStart.Add(time.Duration(i)*time.Second), v)
+	}
+
+	return s
+}
+
+func TestTimeSeriesWraparound(t *testing.T) {
+	s := newTestTimeSeries(3, 1, 2, 3, 4, 5)
+
+	if s.len() != 3 || s.capacity() != 3 {
+		t.Errorf("len %d, capacity %d", s.len(), s.capacity())
+	}
+
+	if got := pointValues(s.all()); !reflect.DeepEqual(got, []float64{3, 4, 5}) {
+		t.Errorf("all is %v", got)
+	}
+
+	if p, ok := s.last(); !ok || p.Value != 5 || !p.Timestamp.Equal(timeSeriesTestStart.Add(4*time.Second)) {
+		t.Errorf("last is %v %v", p, ok)
+	}
+
+	if s.at(0).Value != 3 {
+		t.Errorf("oldest is %v", s.at(0).Value)
+	}
+}
+
+func TestTimeSeriesLastN(t *testing.T) {
+	s := newTestTimeSeries(4, 1, 2, 3, 4, 5, 6)
+
+	tests := []struct {
+		n    int
+		want []float64
+	}{
+		{-1, []float64{}},
+		{0, []float64{}},
+		{2, []float64{5, 6}},
+		{4, []float64{3, 4, 5, 6}},
+		{10, []float64{3, 4, 5, 6}},
+	}
+
+	for _, test := range tests {
+		if got := pointValues(s.lastN(test.n)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("lastN(%d) is %v, want %v", test.n, got, test.want)
+		}
+	}
+
+	empty := NewTimeSeries(4)
+	if got := empty.lastN(3); len(got) != 0 {
+		t.Errorf("lastN on an empty series is %v", got)
+	}
+
+	if _, ok := empty.last(); ok {
+		t.Errorf("empty series has a last point")
+	}
+}
+
+func TestTimeSeriesSince(t *testing.T) {
+	s := newTestTimeSeries(4, 1, 2, 3, 4, 5, 6)
+
+	tests := []struct {
+		from time.Duration
+		want []float64
+	}{
+		{0, []float64{3, 4, 5, 6}},
+		// Inclusive
+		{3 * time.Second, []float64{4, 5, 6}},
+		{3500 * time.Millisecond, []float64{5, 6}},
+		{5 * time.Second, []float64{6}},
+		{time.Minute, []float64{}},
+	}
+
+	for _, test := range tests {
+		if got := pointValues(s.since(timeSeriesTestStart.Add(test.from))); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("since +%v is %v, want %v", test.from, got, test.want)
+		}
+	}
+
+	between := s.between(timeSeriesTestStart.Add(3*time.Second), timeSeriesTestStart.Add(5*time.Second))
+	if got := pointValues(between); !reflect.DeepEqual(got, []float64{4, 5}) {
+		t.Errorf("between is %v", got)
+	}
+}
+
+func TestTimeSeriesCounters(t *testing.T) {
+	if _, ok := newTestTimeSeries(4, 10).rate(); ok {
+		t.Errorf("rate from one point")
+	}
+
+	s := newTestTimeSeries(8, 10, 30)
+
+	if delta, ok := s.delta(); !ok || delta != 20 {
+		t.Errorf("delta is %v %v", delta, ok)
+	}
+
+	if rate, ok := s.rate(); !ok || rate != 20 {
+		t.Errorf("rate is %v %v", rate, ok)
+	}
+
+	// The counter resets between 60 and 5
+	s = newTestTimeSeries(8, 10, 30, 60, 5, 15)
+
+	if rate, ok := s.rate(); !ok || rate != 10 {
+		t.Errorf("rate after the reset is %v %v", rate, ok)
+	}
+
+	rates := s.rates()
+	if got := pointValues(rates); !reflect.DeepEqual(got, []float64{20, 30, 10}) {
+		t.Errorf("rates are %v", got)
+	}
+
+	if !rates[2].Timestamp.Equal(timeSeriesTestStart.Add(4 * time.Second)) {
+		t.Errorf("rate is stamped %v", rates[2].Timestamp)
+	}
+
+	s = newTestTimeSeries(8, 10, 30, 60, 5)
+	if _, ok := s.rate(); ok {
+		t.Errorf("rate across a reset")
+	}
+
+	// Two readings at the same time
+	s = NewTimeSeries(4)
+	s.add(timeSeriesTestStart, 1)
+	s.add(timeSeriesTestStart, 2)
+
+	if _, ok := s.rate(); ok {
+		t.Errorf("rate with no time between")
+	}
+}
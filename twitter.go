@@ -7,6 +7,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/dghubble/go-twitter/twitter"
@@ -55,11 +56,16 @@ func GetLatestTweet(account string) string {
 
 const TwitterWidgetUpdateInterval = 10 * time.Minute
 
+// Enough for a short tweet, so the row doesn't jump around
+const TwitterWidgetMinHeight = 7
+
 type TwitterWidget struct {
 	account     string
 	color       ui.Attribute
 	widget      *ui.Paragraph
 	lastUpdated *time.Time
+	// Before wrapping
+	text string
 }
 
 func NewTwitterWidget(account string, color ui.Attribute) *TwitterWidget {
@@ -89,32 +95,26 @@ func (w *TwitterWidget) getGridWidget() ui.GridBufferer {
 
 func (w *TwitterWidget) update() {
 	if shouldUpdate(w) {
-		// Get latest tweet
-		w.widget.Text = GetLatestTweet(w.account)
+		// Get latest tweet, brackets and all
+		w.text = escapeMarkup(GetLatestTweet(w.account))
 	}
 
 	w.resize()
 }
 
 func (w *TwitterWidget) resize() {
-	borderCount := 0
-	if w.widget.Border {
-		borderCount = 2
-	}
-
-	// Make line wrapping better
-	wrap := w.widget.Width - borderCount
+	wrap := paragraphTextWidth(w.widget)
 	if wrap <= 0 {
 		wrap = 30
 	}
+
+	lines := wrapText(w.text, wrap)
+
+	w.widget.Text = strings.Join(lines, "\n")
+	// Already wrapped, so termui's wrapping has nothing to do, but it still tells attached dashboards to wrap
 	w.widget.WrapLength = wrap
 
-	// Guess at line count
-	height := borderCount + 1 + len(w.widget.Text)/wrap
-	if height < 7 {
-		height = 7
-	}
-	w.widget.Height = height
+	fitParagraphHeight(w.widget, len(lines), TwitterWidgetMinHeight)
 }
 
 func (w *TwitterWidget) getUpdateInterval() time.Duration {
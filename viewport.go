@@ -0,0 +1,202 @@
+package main
+
+/**
+ * Scrolling, for when there's more dashboard than terminal (or more rows than a widget has room for).
+ */
+
+import (
+	"fmt"
+	"image"
+
+	ui "github.com/gizak/termui"
+)
+
+////////////////////////////////////////////
+// Utility: Scrolling
+////////////////////////////////////////////
+
+// How far one click of the mouse wheel goes
+const ScrollWheelLines = 3
+
+// Keeps an offset into something total lines long that shows shown lines at a time
+type ScrollPosition struct {
+	offset int
+	shown  int
+	total  int
+}
+
+func (p *ScrollPosition) maxOffset() int {
+	if p.total <= p.shown {
+		return 0
+	}
+
+	return p.total - p.shown
+}
+
+// For when the sizes change underneath it
+func (p *ScrollPosition) setSize(shown int, total int) {
+	p.shown = shown
+	p.total = total
+	p.scrollTo(p.offset)
+}
+
+// Returns true if it moved
+func (p *ScrollPosition) scrollTo(offset int) bool {
+	if offset > p.maxOffset() {
+		offset = p.maxOffset()
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	moved := offset != p.offset
+	p.offset = offset
+
+	return moved
+}
+
+func (p *ScrollPosition) scroll(lines int) bool {
+	return p.scrollTo(p.offset + lines)
+}
+
+// The usual keys and the mouse wheel, page is how far PgUp/PgDn go.  Returns true if it moved.
+func (p *ScrollPosition) handleScrollEvent(e ui.Event, page int) bool {
+	switch e.ID {
+	case "<PageUp>":
+		return p.scroll(-page)
+	case "<PageDown>":
+		return p.scroll(page)
+	case "<Home>":
+		return p.scrollTo(0)
+	case "<End>":
+		return p.scrollTo(p.maxOffset())
+	case "<MouseWheelUp>":
+		return p.scroll(-ScrollWheelLines)
+	case "<MouseWheelDown>":
+		return p.scroll(ScrollWheelLines)
+	}
+
+	return false
+}
+
+// For wheel events, so the widget under the pointer gets them
+func isMouseOver(e ui.Event, b *ui.Block) bool {
+	mouse, ok := e.Payload.(ui.Mouse)
+
+	return ok && mouse.X >= b.X && mouse.X < b.X+b.Width && mouse.Y >= b.Y && mouse.Y < b.Y+b.Height
+}
+
+// For a border label, like "▲ 11-20 of 45 ▼", with the arrows only when there's more that way.  Empty when everything
+// fits.
+func (p *ScrollPosition) indicator() string {
+	if p.maxOffset() <= 0 {
+		return ""
+	}
+
+	indicator := fmt.Sprintf("%d-%d of %d", p.offset+1, p.offset+p.shown, p.total)
+
+	if p.offset > 0 {
+		indicator = "▲ " + indicator
+	}
+	if p.offset < p.maxOffset() {
+		indicator += " ▼"
+	}
+
+	return indicator
+}
+
+// Which of height rows the scroll bar thumb covers, from top to bottom
+func (p *ScrollPosition) thumb(height int) (int, int) {
+	if p.total <= 0 || height <= 0 {
+		return 0, 0
+	}
+
+	size := height * p.shown / p.total
+	if size < 1 {
+		size = 1
+	}
+
+	start := 0
+	if p.maxOffset() > 0 {
+		start = p.offset * (height - size) / p.maxOffset()
+	}
+
+	return start, start + size
+}
+
+////////////////////////////////////////////
+// Utility: Viewport
+////////////////////////////////////////////
+
+// Shows the part of a grid that fits in an area, with a scroll bar down the column just right of it (the frame's
+// border) when it doesn't all fit
+type Viewport struct {
+	grid     *ui.Grid
+	position ScrollPosition
+	X        int
+	Y        int
+	Width    int
+	Height   int
+}
+
+func NewViewport(grid *ui.Grid) *Viewport {
+	return &Viewport{grid: grid}
+}
+
+func (v *Viewport) setArea(x int, y int, width int, height int) {
+	v.X = x
+	v.Y = y
+	v.Width = width
+	v.Height = height
+}
+
+func (v *Viewport) contentHeight() int {
+	height := 0
+
+	for _, row := range v.grid.Rows {
+		height += row.GetHeight()
+	}
+
+	return height
+}
+
+// Instead of grid.Align(), so the grid ends up scrolled into place
+func (v *Viewport) align() {
+	// Heights first, then where the rows go
+	v.grid.Y = v.Y
+	v.grid.Align()
+
+	v.position.setSize(v.Height, v.contentHeight())
+
+	v.grid.Y = v.Y - v.position.offset
+	v.grid.Align()
+}
+
+func (v *Viewport) handleEvent(e ui.Event) bool {
+	// Keep a line of what was showing, for context
+	return v.position.handleScrollEvent(e, v.Height-1)
+}
+
+func (v *Viewport) Buffer() ui.Buffer {
+	area := image.Rect(v.X, v.Y, v.X+v.Width, v.Y+v.Height)
+
+	// termui only draws what's in the area, so it has to take in the scroll bar too
+	buf := ui.NewBuffer()
+	buf.Area = image.Rect(v.X, v.Y, v.X+v.Width+1, v.Y+v.Height)
+
+	for p, cell := range v.grid.Buffer().CellMap {
+		if p.In(area) {
+			buf.CellMap[p] = cell
+		}
+	}
+
+	if v.position.maxOffset() > 0 {
+		start, end := v.position.thumb(v.Height)
+
+		for y := start; y < end; y++ {
+			buf.Set(v.X+v.Width, v.Y+y, ui.Cell{Ch: '┃', Fg: ui.ColorYellow | ui.AttrBold, Bg: ui.ColorDefault})
+		}
+	}
+
+	return buf
+}
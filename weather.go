@@ -21,15 +21,20 @@ import (
 
 const WeatherWidgetUpdateInterval = 1 * time.Hour
 
+// Until there's weather to show
+const WeatherWidgetMinHeight = 5
+
 type WeatherWidget struct {
 	location    string
-	widget      *ui.Paragraph
+	widget      *ANSIParagraph
 	lastUpdated *time.Time
+	// The ASCII art, in all its colors but not cut to fit
+	lines [][]ANSIRun
 }
 
 func NewWeatherWidget(location string) *WeatherWidget {
 	// Create base element
-	e := ui.NewParagraph("")
+	e := NewANSIParagraph()
 	e.Border = true
 	e.Height = 9
 	e.BorderLabelFg = ui.ColorGreen
@@ -57,7 +62,7 @@ func (w *WeatherWidget) getGridWidget() ui.GridBufferer {
 func (w *WeatherWidget) update() {
 	if shouldUpdate(w) {
 		// Load weather info
-		w.widget.Text = ""
+		w.lines = [][]ANSIRun{}
 
 		client := &http.Client{}
 
@@ -93,12 +98,11 @@ func (w *WeatherWidget) update() {
 
 							if len(parts) > 2 {
 								// Weather
-								w.widget.Text = ConvertANSIToColorStrings(parts[2])
+								w.lines = trimBlankANSILines(splitANSILines(parseANSI(parts[2])))
 							} else if len(parts) > 1 {
 								// Maybe terrible?
-								w.widget.Text = ConvertANSIToColorStrings(parts[1])
+								w.lines = trimBlankANSILines(splitANSILines(parseANSI(parts[1])))
 							}
-							w.widget.Text = strings.TrimRight(w.widget.Text, " \t\n\r\x0A")
 						} else {
 							// Error
 							w.widget.BorderLabel = "Weather: ERROR"
@@ -108,10 +112,40 @@ func (w *WeatherWidget) update() {
 			}
 		}
 	}
+
+	w.resize()
 }
 
+// The art would fall apart wrapped, so lines get cut short instead
 func (w *WeatherWidget) resize() {
-	// Do nothing
+	width := paragraphTextWidth(&w.widget.Paragraph)
+	if width <= 0 {
+		// Not laid out yet
+		width = 80
+	}
+
+	w.widget.setLines(w.lines, width)
+
+	fitParagraphHeight(&w.widget.Paragraph, len(w.lines), WeatherWidgetMinHeight)
+}
+
+// The blank lines wttr.in ends with
+func trimBlankANSILines(lines [][]ANSIRun) [][]ANSIRun {
+	isBlank := func(line []ANSIRun) bool {
+		for _, run := range line {
+			if len(strings.TrimSpace(run.Text)) > 0 {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	for len(lines) > 0 && isBlank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
 }
 
 func (w *WeatherWidget) getUpdateInterval() time.Duration {
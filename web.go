@@ -0,0 +1,164 @@
+package main
+
+/**
+ * Serve the dashboard over HTTP: a small web page plus a JSON API, kept live with Server-Sent Events.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+)
+
+////////////////////////////////////////////
+// Utility: Web Dashboard
+////////////////////////////////////////////
+
+type WebDashboard struct {
+	lock        sync.Mutex
+	subscribers map[chan DashboardState]bool
+}
+
+func NewWebDashboard() *WebDashboard {
+	w := &WebDashboard{
+		subscribers: make(map[chan DashboardState]bool),
+	}
+
+	latestDashboardState.addListener(w.broadcast)
+
+	return w
+}
+
+// Hand the new state to every event stream, skipping any that are behind
+func (w *WebDashboard) broadcast(state DashboardState) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for ch := range w.subscribers {
+		select {
+		case ch <- state:
+		default:
+		}
+	}
+}
+
+func (w *WebDashboard) subscribe() chan DashboardState {
+	ch := make(chan DashboardState, 1)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.subscribers[ch] = true
+
+	return ch
+}
+
+func (w *WebDashboard) unsubscribe(ch chan DashboardState) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	delete(w.subscribers, ch)
+}
+
+func writeJSON(rw http.ResponseWriter, value interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(rw).Encode(value); err != nil {
+		log.Printf("Error writing JSON response: %v", err)
+	}
+}
+
+func (w *WebDashboard) handleIndex(rw http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(rw, req)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(rw, WebDashboardHTML)
+}
+
+func (w *WebDashboard) handleWidgets(rw http.ResponseWriter, req *http.Request) {
+	state := latestDashboardState.get()
+
+	writeJSON(rw, map[string]interface{}{
+		"timestamp": state.Timestamp,
+		"header":    state.Header,
+		"layout":    state.Layout,
+		"widgets":   state.Widgets,
+	})
+}
+
+func (w *WebDashboard) handleRepos(rw http.ResponseWriter, req *http.Request) {
+	writeJSON(rw, latestDashboardState.get().Repos)
+}
+
+func (w *WebDashboard) handleDisks(rw http.ResponseWriter, req *http.Request) {
+	writeJSON(rw, latestDashboardState.get().Disks)
+}
+
+func (w *WebDashboard) handleEvents(rw http.ResponseWriter, req *http.Request) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+
+	ch := w.subscribe()
+	defer w.unsubscribe(ch)
+
+	send := func(state DashboardState) bool {
+		data, err := json.Marshal(state)
+		if err != nil {
+			log.Printf("Error encoding dashboard state: %v", err)
+			return false
+		}
+
+		if _, err := fmt.Fprintf(rw, "event: state\ndata: %s\n\n", data); err != nil {
+			return false
+		}
+
+		flusher.Flush()
+		return true
+	}
+
+	// Start them off with what we've got
+	if !send(latestDashboardState.get()) {
+		return
+	}
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case state := <-ch:
+			if !send(state) {
+				return
+			}
+		}
+	}
+}
+
+func startWebDashboard(address string) {
+	w := NewWebDashboard()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", w.handleIndex)
+	mux.HandleFunc("/api/widgets", w.handleWidgets)
+	mux.HandleFunc("/api/repos", w.handleRepos)
+	mux.HandleFunc("/api/disks", w.handleDisks)
+	mux.HandleFunc("/api/events", w.handleEvents)
+
+	go func() {
+		err := http.ListenAndServe(address, mux)
+
+		if err != nil {
+			log.Printf("Error serving web dashboard on '%v': %v", address, err)
+		}
+	}()
+}
@@ -0,0 +1,151 @@
+package main
+
+/**
+ * The page served by the web dashboard.  It renders the /api/events states with the same layout and colors as the
+ * terminal.
+ */
+
+const WebDashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>sysdash</title>
+<style>
+  body { background: #000; color: #e5e5e5; font: 13px/1.3 monospace; margin: 0; }
+  #header { border: 1px solid #29b8db; margin: 4px; padding: 0 4px 4px; min-height: 95vh; }
+  #header > .title { color: #29b8db; font-weight: bold; }
+  .row { display: flex; flex-wrap: wrap; }
+  .col { box-sizing: border-box; min-width: 320px; }
+  @media (max-width: 700px) { .col { flex-basis: 100% !important; max-width: 100% !important; } }
+  .box { border: 1px solid #e5e5e5; margin: 0 0 0 0; padding: 2px 6px; overflow: hidden; }
+  .box > .title { margin-top: -0.9em; background: #000; display: inline-block; padding: 0 2px; }
+  .box pre { margin: 0; white-space: pre-wrap; word-break: break-word; }
+  .gauge { position: relative; height: 1.3em; background: #222; }
+  .gauge > .bar { position: absolute; top: 0; bottom: 0; left: 0; background: currentColor; }
+  .gauge > .label { position: absolute; right: 4px; color: #fff; font-weight: bold; mix-blend-mode: difference; }
+  table { border-collapse: collapse; width: 100%; }
+  td { padding: 0 6px 0 0; white-space: pre; }
+  svg { width: 100%; height: 12em; }
+  .stale { opacity: 0.5; }
+  .fg-black { color: #666; } .fg-red { color: #cd3131; } .fg-green { color: #0dbc79; }
+  .fg-yellow { color: #e5e510; } .fg-blue { color: #2472c8; } .fg-magenta { color: #bc3fbc; }
+  .fg-cyan { color: #11a8cd; } .fg-white { color: #e5e5e5; }
+  .fg-bold { font-weight: bold; }
+  .fg-bold.fg-black { color: #888; } .fg-bold.fg-red { color: #f14c4c; } .fg-bold.fg-green { color: #23d18b; }
+  .fg-bold.fg-yellow { color: #f5f543; } .fg-bold.fg-blue { color: #3b8eea; } .fg-bold.fg-magenta { color: #d670d6; }
+  .fg-bold.fg-cyan { color: #29b8db; } .fg-bold.fg-white { color: #fff; }
+  .fg-underline { text-decoration: underline; }
+  .bg-black { background: #000; } .bg-red { background: #cd3131; } .bg-green { background: #0dbc79; }
+  .bg-yellow { background: #e5e510; } .bg-blue { background: #2472c8; } .bg-magenta { background: #bc3fbc; }
+  .bg-cyan { background: #11a8cd; } .bg-white { background: #e5e5e5; }
+</style>
+</head>
+<body>
+<div id="header"><span class="title">sysdash</span><div id="body"></div></div>
+<script>
+function escapeHTML(s) {
+  return s.replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;");
+}
+
+function classes(attrs) {
+  return (attrs || "").split(",").join(" ");
+}
+
+// Turn termui [text](fg-color,fg-bold) markup into spans
+function markup(s) {
+  var re = /\[([^\]]*)\]\(((?:fg|bg)-[a-z,\-]+)\)/g;
+  var out = "", last = 0, m;
+  while ((m = re.exec(s || "")) !== null) {
+    out += escapeHTML(s.substring(last, m.index));
+    out += '<span class="' + classes(m[2]) + '">' + escapeHTML(m[1]) + '</span>';
+    last = re.lastIndex;
+  }
+  return out + escapeHTML((s || "").substring(last));
+}
+
+function box(w, inner) {
+  return '<div class="box ' + classes(w.borderColor) + '">' +
+    (w.title ? '<span class="title ' + classes(w.titleColor) + '">' + markup(w.title) + '</span>' : '') +
+    inner + '</div>';
+}
+
+function renderLines(w) {
+  return box(w, '<pre class="' + classes(w.textColor) + '">' + (w.lines || []).map(markup).join("\n") + '</pre>');
+}
+
+function renderGauge(w) {
+  return box(w, '<div class="gauge ' + classes(w.barColor) + '"><div class="bar" style="width:' + (w.percent || 0) +
+    '%"></div><span class="label">' + escapeHTML(w.label || "") + '</span></div>');
+}
+
+function renderTable(w) {
+  var rows = (w.rows || []).map(function (row) {
+    return '<tr>' + row.map(function (cell) { return '<td>' + markup(cell) + '</td>'; }).join("") + '</tr>';
+  });
+  return box(w, '<table>' + rows.join("") + '</table>');
+}
+
+function renderChart(w) {
+  var max = 0, len = 0;
+  Object.keys(w.series || {}).forEach(function (k) {
+    w.series[k].forEach(function (v) { max = Math.max(max, v); });
+    len = Math.max(len, w.series[k].length);
+  });
+  max = max || 1;
+  var lines = Object.keys(w.series || {}).map(function (k) {
+    var pts = w.series[k].map(function (v, i) {
+      return (len > 1 ? 100 * i / (len - 1) : 0).toFixed(2) + "," + (100 - 100 * v / max).toFixed(2);
+    });
+    return '<polyline class="' + classes(w.seriesColor[k]) + '" fill="none" stroke="currentColor" ' +
+      'vector-effect="non-scaling-stroke" points="' + pts.join(" ") + '"/>';
+  });
+  var labels = w.dataLabels || [];
+  // Sparklines are scaled, their numbers don't mean anything
+  var axis = w.kind === "sparkline" ? "" : '<span class="' + classes(w.axesColor) + '">' + max.toFixed(2) + ' max' +
+    (labels.length ? ', ' + escapeHTML(labels[0]) + ' - ' + escapeHTML(labels[labels.length - 1]) : '') + '</span>';
+  return box(w, '<svg viewBox="0 0 100 100" preserveAspectRatio="none">' + lines.join("") + '</svg>' + axis);
+}
+
+function renderWidget(w) {
+  switch (w.kind) {
+    case "paragraph":
+    case "list": return renderLines(w);
+    case "gauge": return renderGauge(w);
+    case "table": return renderTable(w);
+    case "linechart":
+    case "sparkline": return renderChart(w);
+    case "column": return (w.children || []).map(renderWidget).join("");
+  }
+  return "";
+}
+
+function render(state) {
+  var byName = {};
+  (state.widgets || []).forEach(function (w) { byName[w.name] = w; });
+  document.querySelector("#header > .title").textContent = state.header || "sysdash";
+  document.getElementById("body").innerHTML = (state.layout || []).map(function (row) {
+    return '<div class="row">' + row.map(function (col) {
+      var pct = (100 * col.span / 12) + "%";
+      return '<div class="col" style="flex-basis:' + pct + ';max-width:' + pct + '">' +
+        col.widgets.map(function (name) { return byName[name] ? renderWidget(byName[name]) : ""; }).join("") +
+        '</div>';
+    }).join("") + '</div>';
+  }).join("");
+}
+
+function connect() {
+  var body = document.getElementById("body");
+  var events = new EventSource("api/events");
+  events.addEventListener("state", function (e) {
+    body.classList.remove("stale");
+    render(JSON.parse(e.data));
+  });
+  events.onerror = function () { body.classList.add("stale"); };
+}
+
+fetch("api/widgets").then(function (r) { return r.json(); }).then(render).then(connect, connect);
+</script>
+</body>
+</html>
+`
@@ -20,6 +20,22 @@ type CAHWidget interface {
 	resize()
 }
 
+// Widgets that react to key presses (or other events) from the rendering loop.  Returns true if it needs redrawing.
+type EventHandler interface {
+	handleEvent(e ui.Event) bool
+}
+
+// Widgets that take some keys only while they have the focus, so the keys stay free for everything else.  Returns true
+// if it needs redrawing.
+type FocusedEventHandler interface {
+	handleFocusedEvent(e ui.Event) bool
+}
+
+// Widgets with a smaller version for small terminals
+type CompactWidget interface {
+	setCompact(compact bool)
+}
+
 type UpdateInterval interface {
 	getUpdateInterval() time.Duration
 	getLastUpdated() *time.Time